@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"snippetbox.cozycole.net/internal/models"
+)
+
+// client talks to the snippetbox JSON API as the owner of token.
+type client struct {
+	server string
+	token  string
+	http   *http.Client
+}
+
+// snippetRequest is the body sent to create a snippet. It matches the fields
+// of the server's snippet form.
+type snippetRequest struct {
+	Title      string   `json:"title"`
+	Content    string   `json:"content"`
+	Visibility string   `json:"visibility,omitempty"`
+	Language   string   `json:"language,omitempty"`
+	Format     string   `json:"format,omitempty"`
+	Tags       []string `json:"tags,omitempty"`
+	Expires    string   `json:"expires,omitempty"`
+}
+
+type snippetList struct {
+	Snippets []*models.Snippet `json:"snippets"`
+}
+
+// apiError is a problem details response from the server.
+type apiError struct {
+	Status int               `json:"status"`
+	Title  string            `json:"title"`
+	Detail string            `json:"detail"`
+	Errors map[string]string `json:"errors"`
+}
+
+func (e *apiError) Error() string {
+	msg := fmt.Sprintf("%d %s", e.Status, e.Title)
+	if e.Detail != "" {
+		msg += ": " + e.Detail
+	}
+
+	fields := make([]string, 0, len(e.Errors))
+	for field := range e.Errors {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	for _, field := range fields {
+		msg += fmt.Sprintf("\n  %s: %s", field, e.Errors[field])
+	}
+	return msg
+}
+
+// create posts a new snippet and returns it as saved by the server.
+func (c *client) create(req snippetRequest) (*models.Snippet, error) {
+	var snippet models.Snippet
+	err := c.do(http.MethodPost, "/api/v1/snippets", req, &snippet)
+	if err != nil {
+		return nil, err
+	}
+	return &snippet, nil
+}
+
+func (c *client) get(id string) (*models.Snippet, error) {
+	var snippet models.Snippet
+	err := c.do(http.MethodGet, "/api/v1/snippets/"+url.PathEscape(id), nil, &snippet)
+	if err != nil {
+		return nil, err
+	}
+	return &snippet, nil
+}
+
+// mine returns the snippets belonging to the token's owner.
+func (c *client) mine() ([]*models.Snippet, error) {
+	var list snippetList
+	err := c.do(http.MethodGet, "/api/v1/user/snippets", nil, &list)
+	if err != nil {
+		return nil, err
+	}
+	return list.Snippets, nil
+}
+
+// viewURL is the address of a snippet's page on the server.
+func (c *client) viewURL(snippet *models.Snippet) string {
+	return strings.TrimSuffix(c.server, "/") + "/snippet/view/" + snippet.Slug
+}
+
+// do sends a request with in as its JSON body, if it isn't nil, and decodes
+// the JSON response into out. Error responses are returned as an *apiError.
+func (c *client) do(method, path string, in any, out any) error {
+	var body io.Reader
+	if in != nil {
+		js, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(js)
+	}
+
+	req, err := http.NewRequest(method, strings.TrimSuffix(c.server, "/")+path, body)
+	if err != nil {
+		return err
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	rs, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer rs.Body.Close()
+
+	if rs.StatusCode >= 400 {
+		mediaType, _, _ := mime.ParseMediaType(rs.Header.Get("Content-Type"))
+		if mediaType != "application/problem+json" {
+			return fmt.Errorf("unexpected response from server: %s", rs.Status)
+		}
+
+		apiErr := &apiError{}
+		err := json.NewDecoder(rs.Body).Decode(apiErr)
+		if err != nil {
+			return fmt.Errorf("unexpected response from server: %s", rs.Status)
+		}
+		return apiErr
+	}
+
+	return json.NewDecoder(rs.Body).Decode(out)
+}
@@ -0,0 +1,255 @@
+// Command snippet posts and fetches snippets from a snippetbox server over
+// its JSON API, so they can be shared straight from a terminal or CI job.
+//
+// Usage:
+//
+//	snippet [-config file] post [-title title] [-expires when] [-visibility visibility] [-language language] [-format format] [-tags tags] [file]
+//	snippet [-config file] get id
+//	snippet [-config file] list
+//
+// post reads the snippet from file, or from standard input if no file is
+// given, and prints the URL of the new snippet. get prints a snippet's
+// content and list shows your own snippets.
+//
+// The server and a personal access token are read from a JSON config file,
+// by default snippetbox/config.json in the user config directory:
+//
+//	{
+//		"server": "https://snippetbox.example.com",
+//		"token": "sbx_...",
+//		"ca_file": "/path/to/cert.pem"
+//	}
+//
+// ca_file is optional, and adds a certificate to trust, such as the self
+// signed one used in development.
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+type config struct {
+	Server string `json:"server"`
+	Token  string `json:"token"`
+	CAFile string `json:"ca_file"`
+}
+
+// errUsage is returned when the command line is wrong, after the usage has
+// already been printed.
+var errUsage = errors.New("usage")
+
+func main() {
+	err := run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr)
+	if err != nil {
+		if errors.Is(err, errUsage) {
+			os.Exit(2)
+		}
+		fmt.Fprintf(os.Stderr, "snippet: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	flags := flag.NewFlagSet("snippet", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "usage: snippet [-config file] post|get|list [arguments]")
+		flags.PrintDefaults()
+	}
+	configPath := flags.String("config", defaultConfigPath(), "config file with the server and access token")
+
+	err := flags.Parse(args)
+	if err != nil {
+		return errUsage
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return errUsage
+	}
+
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+
+	c, err := newClient(cfg)
+	if err != nil {
+		return err
+	}
+
+	command, args := flags.Arg(0), flags.Args()[1:]
+	switch command {
+	case "post":
+		return post(c, args, stdin, stdout, stderr)
+	case "get":
+		return get(c, args, stdout, stderr)
+	case "list":
+		return list(c, args, stdout, stderr)
+	default:
+		flags.Usage()
+		return errUsage
+	}
+}
+
+func post(c *client, args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	flags := flag.NewFlagSet("post", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "usage: snippet post [flags] [file]")
+		flags.PrintDefaults()
+	}
+	title := flags.String("title", "", "snippet title (defaults to the file name)")
+	expires := flags.String("expires", "365d", "when the snippet is deleted: burn (after it's read), 1h, 6h, 1d, 7d, 30d, 365d or never")
+	visibility := flags.String("visibility", "public", "public, unlisted or private")
+	language := flags.String("language", "auto", "language to highlight the snippet as")
+	format := flags.String("format", "plain", "plain or markdown")
+	tags := flags.String("tags", "", "comma separated tags, up to 5")
+
+	err := flags.Parse(args)
+	if err != nil || flags.NArg() > 1 {
+		return errUsage
+	}
+
+	var content []byte
+	if flags.NArg() == 1 {
+		content, err = os.ReadFile(flags.Arg(0))
+		if *title == "" {
+			*title = filepath.Base(flags.Arg(0))
+		}
+	} else {
+		content, err = io.ReadAll(stdin)
+	}
+	if err != nil {
+		return err
+	}
+
+	if *title == "" {
+		return errors.New("a -title is needed when reading from standard input")
+	}
+
+	snippet, err := c.create(snippetRequest{
+		Title:      *title,
+		Content:    string(content),
+		Visibility: *visibility,
+		Language:   *language,
+		Format:     *format,
+		Tags:       strings.FieldsFunc(*tags, func(r rune) bool { return r == ',' }),
+		Expires:    *expires,
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(stdout, c.viewURL(snippet))
+	return nil
+}
+
+func get(c *client, args []string, stdout, stderr io.Writer) error {
+	if len(args) != 1 {
+		fmt.Fprintln(stderr, "usage: snippet get id")
+		return errUsage
+	}
+
+	snippet, err := c.get(args[0])
+	if err != nil {
+		return err
+	}
+
+	// Print the content exactly as it was saved so it can be piped on
+	_, err = io.WriteString(stdout, snippet.Content)
+	return err
+}
+
+func list(c *client, args []string, stdout, stderr io.Writer) error {
+	if len(args) != 0 {
+		fmt.Fprintln(stderr, "usage: snippet list")
+		return errUsage
+	}
+
+	snippets, err := c.mine()
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tVISIBILITY\tEXPIRES\tTITLE")
+	for _, s := range snippets {
+		expires := s.Expires.UTC().Format("2006-01-02")
+		if s.Permanent() {
+			expires = "never"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", s.Slug, s.Visibility, expires, s.Title)
+	}
+	return tw.Flush()
+}
+
+func defaultConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "snippetbox.json"
+	}
+	return filepath.Join(dir, "snippetbox", "config.json")
+}
+
+func loadConfig(path string) (*config, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading config: %w", err)
+	}
+
+	cfg := &config{}
+	err = json.Unmarshal(b, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("reading config %s: %w", path, err)
+	}
+
+	// Tokens must never be sent in the clear
+	u, err := url.Parse(cfg.Server)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return nil, fmt.Errorf("config %s: server must be an https:// URL", path)
+	}
+
+	return cfg, nil
+}
+
+func newClient(cfg *config) (*client, error) {
+	tlsConfig := &tls.Config{}
+
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading ca_file: %w", err)
+		}
+
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("ca_file %s contains no certificates", cfg.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	return &client{
+		server: cfg.Server,
+		token:  cfg.Token,
+		http: &http.Client{
+			Timeout:   30 * time.Second,
+			Transport: &http.Transport{TLSClientConfig: tlsConfig, Proxy: http.ProxyFromEnvironment},
+		},
+	}, nil
+}
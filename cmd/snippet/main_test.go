@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"snippetbox.cozycole.net/internal/assert"
+	"snippetbox.cozycole.net/internal/models"
+)
+
+var testSnippet = &models.Snippet{
+	Slug:       "pond4Xk9Qa",
+	Author:     "Alice Smith",
+	Title:      "An old silent pond",
+	Content:    "An old silent pond...\n",
+	Visibility: models.VisibilityPublic,
+	Expires:    time.Date(2099, 1, 1, 10, 0, 0, 0, time.UTC),
+}
+
+var testPermanentSnippet = &models.Snippet{
+	Slug:       "forest7BcD",
+	Author:     "Alice Smith",
+	Title:      "Over the wintry forest",
+	Content:    "Over the wintry forest, winds howl in rage...\n",
+	Visibility: models.VisibilityPublic,
+	Expires:    models.NoExpiry,
+}
+
+// newTestAPI starts a TLS server which imitates the parts of the API the
+// command uses, and writes a config file for talking to it.
+func newTestAPI(t *testing.T) string {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/snippets", func(w http.ResponseWriter, r *http.Request) {
+		var req snippetRequest
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil || r.Header.Get("Content-Type") != "application/json" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if req.Title == "" {
+			w.Header().Set("Content-Type", "application/problem+json")
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			w.Write([]byte(`{"status": 422, "title": "Unprocessable Entity", "errors": {"title": "This field cannot be blank"}}`))
+			return
+		}
+		json.NewEncoder(w).Encode(&models.Snippet{Slug: "new5HjKmNp", Title: req.Title, Content: req.Content})
+	})
+	mux.HandleFunc("/api/v1/snippets/pond4Xk9Qa", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(testSnippet)
+	})
+	mux.HandleFunc("/api/v1/user/snippets", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(snippetList{Snippets: []*models.Snippet{testSnippet, testPermanentSnippet}})
+	})
+
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer sbx_test" {
+			w.Header().Set("Content-Type", "application/problem+json")
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"status": 401, "title": "Unauthorized"}`))
+			return
+		}
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(ts.Close)
+
+	dir := t.TempDir()
+
+	caFile := filepath.Join(dir, "cert.pem")
+	cert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw})
+	err := os.WriteFile(caFile, cert, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	configFile := filepath.Join(dir, "config.json")
+	cfg, _ := json.Marshal(config{Server: ts.URL, Token: "sbx_test", CAFile: caFile})
+	err = os.WriteFile(configFile, cfg, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return configFile
+}
+
+func TestRun(t *testing.T) {
+	configFile := newTestAPI(t)
+
+	tests := []struct {
+		name       string
+		args       []string
+		stdin      string
+		wantErr    string
+		wantStdout string
+	}{
+		{
+			name:       "Post",
+			args:       []string{"post", "-title", "deploy", "-expires", "7d", "-tags", "ci,deploy"},
+			stdin:      "echo deploying\n",
+			wantStdout: "/snippet/view/new5HjKmNp\n",
+		},
+		{
+			name:    "Post without a title",
+			args:    []string{"post"},
+			stdin:   "echo deploying\n",
+			wantErr: "a -title is needed",
+		},
+		{
+			name:       "Get",
+			args:       []string{"get", "pond4Xk9Qa"},
+			wantStdout: "An old silent pond...\n",
+		},
+		{
+			name:    "Get a missing snippet",
+			args:    []string{"get", "zzzzzzzzzz"},
+			wantErr: "unexpected response from server: 404 Not Found",
+		},
+		{
+			name:       "List",
+			args:       []string{"list"},
+			wantStdout: "pond4Xk9Qa  public      2099-01-01  An old silent pond\n",
+		},
+		{
+			name:       "List a snippet which never expires",
+			args:       []string{"list"},
+			wantStdout: "forest7BcD  public      never       Over the wintry forest\n",
+		},
+		{
+			name:    "Unknown command",
+			args:    []string{"frobnicate"},
+			wantErr: "usage",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+
+			args := append([]string{"-config", configFile}, tt.args...)
+			err := run(args, strings.NewReader(tt.stdin), &stdout, &stderr)
+
+			if tt.wantErr != "" {
+				if err == nil {
+					t.Fatalf("got no error; want %q", tt.wantErr)
+				}
+				assert.StringContains(t, err.Error(), tt.wantErr)
+				return
+			}
+
+			assert.NilError(t, err)
+			assert.StringContains(t, stdout.String(), tt.wantStdout)
+		})
+	}
+}
+
+func TestAPIError(t *testing.T) {
+	err := &apiError{
+		Status: 422,
+		Title:  "Unprocessable Entity",
+		Detail: "The request contains invalid fields",
+		Errors: map[string]string{"title": "This field cannot be blank", "expires": "This field must equal 1, 7, or 365"},
+	}
+
+	want := "422 Unprocessable Entity: The request contains invalid fields\n" +
+		"  expires: This field must equal 1, 7, or 365\n" +
+		"  title: This field cannot be blank"
+
+	assert.Equal(t, err.Error(), want)
+}
+
+func TestLoadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	err := os.WriteFile(path, []byte(`{"server": "http://localhost:4000", "token": "sbx_test"}`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = loadConfig(path)
+	if err == nil {
+		t.Fatal("got no error for a plain http server")
+	}
+	assert.StringContains(t, err.Error(), "https://")
+}
@@ -0,0 +1,441 @@
+package main
+
+// Handlers for the JSON API served under /api/v1. They work on the same
+// models and validation as the HTML pages, but read and write JSON, and
+// report errors as RFC 7807 problem details instead of plain text.
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"snippetbox.cozycole.net/internal/highlight"
+	"snippetbox.cozycole.net/internal/models"
+	"snippetbox.cozycole.net/internal/validator"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+const (
+	apiDefaultPerPage = 20
+	apiMaxPerPage     = 100
+	// Snippets are text, so 1MB is plenty for a request body
+	apiMaxBodyBytes = 1 << 20
+)
+
+// A problem is an RFC 7807 problem details object. Errors holds any
+// validation errors keyed by field name.
+type problem struct {
+	Type     string            `json:"type"`
+	Title    string            `json:"title"`
+	Status   int               `json:"status"`
+	Detail   string            `json:"detail,omitempty"`
+	Instance string            `json:"instance,omitempty"`
+	Errors   map[string]string `json:"errors,omitempty"`
+}
+
+type snippetList struct {
+	Snippets []*models.Snippet `json:"snippets"`
+	Page     int               `json:"page"`
+	PerPage  int               `json:"per_page"`
+	// Next is the URL of the following page, if there is one
+	Next string `json:"next,omitempty"`
+}
+
+func (app *application) apiSnippetList(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	var v validator.Validator
+	page := queryInt(query, "page", 1, &v)
+	perPage := queryInt(query, "per_page", apiDefaultPerPage, &v)
+	v.CheckField(page >= 1, "page", "This field must be at least 1")
+	v.CheckField(perPage >= 1 && perPage <= apiMaxPerPage, "per_page", fmt.Sprintf("This field must be between 1 and %d", apiMaxPerPage))
+
+	if !v.Valid() {
+		app.apiFailedValidation(w, r, http.StatusBadRequest, v)
+		return
+	}
+
+	// Ask for one more than needed to find out if there's another page
+	snippets, err := app.snippets.List(r.Context(), perPage+1, (page-1)*perPage)
+	if err != nil {
+		app.apiServerError(w, r, err)
+		return
+	}
+
+	list := snippetList{
+		Snippets: snippets,
+		Page:     page,
+		PerPage:  perPage,
+	}
+	if len(snippets) > perPage {
+		list.Snippets = snippets[:perPage]
+		list.Next = fmt.Sprintf("/api/v1/snippets?page=%d&per_page=%d", page+1, perPage)
+	}
+
+	app.writeJSON(w, r, http.StatusOK, list)
+}
+
+func (app *application) apiSnippetView(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.apiRequestedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	app.writeJSON(w, r, http.StatusOK, snippet)
+}
+
+func (app *application) apiSnippetCreate(w http.ResponseWriter, r *http.Request) {
+	// Fields left out of the request body keep the same defaults as the
+	// create page
+	form := snippetCreateForm{
+		Visibility: models.VisibilityPublic,
+		Language:   highlight.Auto,
+		Format:     models.FormatPlain,
+		Expires:    "365d",
+	}
+
+	if !app.decodeJSON(w, r, &form) {
+		return
+	}
+
+	form.validate(app.allowNeverExpires)
+
+	if !form.Valid() {
+		app.apiFailedValidation(w, r, http.StatusUnprocessableEntity, form.Validator)
+		return
+	}
+
+	expires, burn := form.expires(time.Now())
+	slug, err := app.snippets.Insert(r.Context(), app.authenticatedUserID(r), form.Title, form.Content, form.Visibility, form.language(), form.Format, form.Tags, expires, burn)
+	if err != nil {
+		app.apiServerError(w, r, err)
+		return
+	}
+	app.metrics.snippetsCreated.Inc()
+
+	snippet, err := app.snippets.Peek(r.Context(), slug)
+	if err != nil {
+		app.apiServerError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("/api/v1/snippets/%s", slug))
+	app.writeJSON(w, r, http.StatusCreated, snippet)
+}
+
+func (app *application) apiSnippetUpdate(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.apiOwnedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	// Start from the current snippet so that only the fields being changed
+	// need to be sent. Expires is left blank, which keeps the snippet's
+	// expiry as it is unless another is sent.
+	form := snippetCreateForm{
+		Title:      snippet.Title,
+		Content:    snippet.Content,
+		Visibility: snippet.Visibility,
+		Language:   snippet.Language,
+		Format:     snippet.Format,
+		Tags:       snippet.Tags,
+	}
+
+	if !app.decodeJSON(w, r, &form) {
+		return
+	}
+
+	form.validateEdit(snippet, app.allowNeverExpires)
+
+	if !form.Valid() {
+		app.apiFailedValidation(w, r, http.StatusUnprocessableEntity, form.Validator)
+		return
+	}
+
+	expires, burn := form.editExpires(snippet, time.Now())
+	err := app.snippets.Update(r.Context(), snippet.ID, app.authenticatedUserID(r), form.Title, form.Content, form.Visibility, form.language(), form.Format, form.Tags, expires, burn)
+	if err != nil {
+		app.apiServerError(w, r, err)
+		return
+	}
+
+	snippet, err = app.snippets.Peek(r.Context(), snippet.Slug)
+	if err != nil {
+		app.apiServerError(w, r, err)
+		return
+	}
+
+	app.writeJSON(w, r, http.StatusOK, snippet)
+}
+
+func (app *application) apiSnippetDelete(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.apiOwnedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.snippets.Delete(r.Context(), snippet.ID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.apiNotFound(w, r)
+		} else {
+			app.apiServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// apiUserSnippets lists all of the authenticated user's unexpired snippets,
+// whatever their visibility.
+func (app *application) apiUserSnippets(w http.ResponseWriter, r *http.Request) {
+	snippets, err := app.snippets.ByUser(r.Context(), app.authenticatedUserID(r))
+	if err != nil {
+		app.apiServerError(w, r, err)
+		return
+	}
+
+	app.writeJSON(w, r, http.StatusOK, snippetList{
+		Snippets: snippets,
+		Page:     1,
+		PerPage:  len(snippets),
+	})
+}
+
+// apiRequestedSnippet is the API version of requestedSnippet. Unlike the
+// HTML pages, the API never had numeric IDs so there's nothing to redirect.
+func (app *application) apiRequestedSnippet(w http.ResponseWriter, r *http.Request) (*models.Snippet, bool) {
+	snippet, ok := app.apiFindSnippet(w, r)
+	if !ok {
+		return nil, false
+	}
+
+	if snippet.BurnAfterRead && snippet.UserID != app.authenticatedUserID(r) {
+		var err error
+		snippet, err = app.snippets.Get(r.Context(), snippet.Slug)
+		if err != nil {
+			if errors.Is(err, models.ErrNoRecord) {
+				app.apiNotFound(w, r)
+			} else {
+				app.apiServerError(w, r, err)
+			}
+			return nil, false
+		}
+	}
+
+	return snippet, true
+}
+
+// apiFindSnippet is the API version of findSnippet.
+func (app *application) apiFindSnippet(w http.ResponseWriter, r *http.Request) (*models.Snippet, bool) {
+	params := httprouter.ParamsFromContext(r.Context())
+	slug := params.ByName("id")
+
+	if !models.ValidSlug(slug) {
+		app.apiNotFound(w, r)
+		return nil, false
+	}
+
+	snippet, err := app.snippets.Peek(r.Context(), slug)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.apiNotFound(w, r)
+		} else {
+			app.apiServerError(w, r, err)
+		}
+		return nil, false
+	}
+
+	if !app.canView(r, snippet) {
+		app.apiNotFound(w, r)
+		return nil, false
+	}
+
+	return snippet, true
+}
+
+// apiOwnedSnippet is the API version of ownedSnippet.
+func (app *application) apiOwnedSnippet(w http.ResponseWriter, r *http.Request) (*models.Snippet, bool) {
+	snippet, ok := app.apiFindSnippet(w, r)
+	if !ok {
+		return nil, false
+	}
+
+	if snippet.UserID != app.authenticatedUserID(r) {
+		app.apiProblem(w, r, http.StatusForbidden, "You can only change your own snippets")
+		return nil, false
+	}
+
+	return snippet, true
+}
+
+func (app *application) requireAPIAuthentication(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !app.isAutheticated(r) {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			app.apiProblem(w, r, http.StatusUnauthorized, "You must be logged in or send an access token to do that")
+			return
+		}
+		w.Header().Add("Cache-Control", "no-store")
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// writeJSON sends data as the JSON response body with the given status.
+func (app *application) writeJSON(w http.ResponseWriter, r *http.Request, status int, data any) {
+	// Encode to a buffer first so that an error can still be reported
+	// properly
+	js, err := json.Marshal(data)
+	if err != nil {
+		app.apiServerError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(js)
+	w.Write([]byte("\n"))
+}
+
+// decodeJSON reads a single JSON object from the request body into dst. If
+// ok is false a problem response describing what was wrong has already been
+// sent.
+//
+// Only requests with a JSON Content-Type are accepted. Besides being more
+// predictable, this is what protects the API from CSRF, as a cross-site form
+// can't send one without the browser checking with us first.
+func (app *application) decodeJSON(w http.ResponseWriter, r *http.Request, dst any) bool {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "application/json" {
+		app.apiProblem(w, r, http.StatusUnsupportedMediaType, "The request body must be sent as application/json")
+		return false
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, apiMaxBodyBytes)
+
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+
+	err := dec.Decode(dst)
+	if err == nil {
+		// Anything after the first value is a mistake too
+		if dec.Decode(&struct{}{}) != io.EOF {
+			app.apiProblem(w, r, http.StatusBadRequest, "The request body must only contain a single JSON object")
+			return false
+		}
+		return true
+	}
+
+	var syntaxError *json.SyntaxError
+	var typeError *json.UnmarshalTypeError
+	var maxBytesError *http.MaxBytesError
+
+	switch {
+	case errors.As(err, &syntaxError):
+		app.apiProblem(w, r, http.StatusBadRequest, fmt.Sprintf("The request body contains badly-formed JSON (at character %d)", syntaxError.Offset))
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		app.apiProblem(w, r, http.StatusBadRequest, "The request body contains badly-formed JSON")
+	case errors.As(err, &typeError):
+		if typeError.Field != "" {
+			app.apiProblem(w, r, http.StatusBadRequest, fmt.Sprintf("The request body contains the wrong type for the %q field", typeError.Field))
+		} else {
+			app.apiProblem(w, r, http.StatusBadRequest, "The request body must be a JSON object")
+		}
+	case errors.Is(err, io.EOF):
+		app.apiProblem(w, r, http.StatusBadRequest, "The request body must not be empty")
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		// There's no error type for this, so the field name has to be taken
+		// from the message
+		field := strings.TrimPrefix(err.Error(), "json: unknown field ")
+		app.apiProblem(w, r, http.StatusBadRequest, fmt.Sprintf("The request body contains the unknown field %s", field))
+	case errors.As(err, &maxBytesError):
+		app.apiProblem(w, r, http.StatusRequestEntityTooLarge, fmt.Sprintf("The request body must not be larger than %d bytes", maxBytesError.Limit))
+	default:
+		app.apiServerError(w, r, err)
+	}
+	return false
+}
+
+// queryInt reads an integer from the query string, returning def if it's
+// missing and recording a field error on v if it isn't an integer.
+func queryInt(query url.Values, key string, def int, v *validator.Validator) int {
+	s := query.Get(key)
+	if s == "" {
+		return def
+	}
+
+	i, err := strconv.Atoi(s)
+	if err != nil {
+		v.AddFieldError(key, "This field must be an integer")
+		return def
+	}
+	return i
+}
+
+// apiProblem sends a problem details response. As there's no documentation
+// to link to, the type is always about:blank and the title is the status
+// text.
+func (app *application) apiProblem(w http.ResponseWriter, r *http.Request, status int, detail string) {
+	app.writeProblem(w, problem{
+		Type:     "about:blank",
+		Title:    http.StatusText(status),
+		Status:   status,
+		Detail:   detail,
+		Instance: r.URL.Path,
+	})
+}
+
+// apiFailedValidation sends the field errors collected by v as a problem
+// details response.
+func (app *application) apiFailedValidation(w http.ResponseWriter, r *http.Request, status int, v validator.Validator) {
+	detail := "The request contains invalid fields"
+	if len(v.NonFieldErrors) > 0 {
+		detail = strings.Join(v.NonFieldErrors, ". ")
+	}
+
+	app.writeProblem(w, problem{
+		Type:     "about:blank",
+		Title:    http.StatusText(status),
+		Status:   status,
+		Detail:   detail,
+		Instance: r.URL.Path,
+		Errors:   v.FieldErrors,
+	})
+}
+
+func (app *application) apiNotFound(w http.ResponseWriter, r *http.Request) {
+	app.apiProblem(w, r, http.StatusNotFound, "The requested resource could not be found")
+}
+
+// apiServerError is the API version of serverError. The trace is only
+// included in the response in debug mode.
+func (app *application) apiServerError(w http.ResponseWriter, r *http.Request, err error) {
+	trace := app.logServerError(r, err)
+	status := serverErrorStatus(w, err)
+
+	detail := ""
+	if app.debugMode {
+		detail = trace
+	}
+	app.apiProblem(w, r, status, detail)
+}
+
+func (app *application) writeProblem(w http.ResponseWriter, p problem) {
+	// A problem only holds strings and ints, so it always marshals
+	js, _ := json.Marshal(p)
+
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(p.Status)
+	w.Write(js)
+	w.Write([]byte("\n"))
+}
@@ -0,0 +1,441 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"snippetbox.cozycole.net/internal/assert"
+	"snippetbox.cozycole.net/internal/models/mocks"
+)
+
+func TestAPISnippetList(t *testing.T) {
+	app := newTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name         string
+		urlPath      string
+		wantCode     int
+		wantSnippets int
+		wantNext     string
+		wantErrors   map[string]string
+	}{
+		{
+			name:         "Defaults",
+			urlPath:      "/api/v1/snippets",
+			wantCode:     http.StatusOK,
+			wantSnippets: 2,
+		},
+		{
+			name:         "First page",
+			urlPath:      "/api/v1/snippets?per_page=1",
+			wantCode:     http.StatusOK,
+			wantSnippets: 1,
+			wantNext:     "/api/v1/snippets?page=2&per_page=1",
+		},
+		{
+			name:         "Last page",
+			urlPath:      "/api/v1/snippets?page=2&per_page=1",
+			wantCode:     http.StatusOK,
+			wantSnippets: 1,
+		},
+		{
+			name:       "Invalid page",
+			urlPath:    "/api/v1/snippets?page=0&per_page=foo",
+			wantCode:   http.StatusBadRequest,
+			wantErrors: map[string]string{"page": "This field must be at least 1", "per_page": "This field must be an integer"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.urlPath)
+
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantErrors != nil {
+				p := decodeProblem(t, body)
+				for field, message := range tt.wantErrors {
+					assert.Equal(t, p.Errors[field], message)
+				}
+				return
+			}
+
+			var list snippetList
+			err := json.Unmarshal([]byte(body), &list)
+			assert.NilError(t, err)
+			assert.Equal(t, len(list.Snippets), tt.wantSnippets)
+			assert.Equal(t, list.Next, tt.wantNext)
+		})
+	}
+}
+
+func TestAPISnippetView(t *testing.T) {
+	app := newTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "Valid slug",
+			urlPath:  "/api/v1/snippets/pond4Xk9Qa",
+			wantCode: http.StatusOK,
+			wantBody: `"id":"pond4Xk9Qa"`,
+		},
+		{
+			name:     "Private snippet of another user",
+			urlPath:  "/api/v1/snippets/autumn2EfG",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Numeric ID",
+			urlPath:  "/api/v1/snippets/1",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Query timed out",
+			urlPath:  "/api/v1/snippets/" + mocks.SlowSlug,
+			wantCode: http.StatusServiceUnavailable,
+		},
+		{
+			name:     "Unknown route",
+			urlPath:  "/api/v1/foo",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, headers, body := ts.get(t, tt.urlPath)
+
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantBody != "" {
+				assert.Equal(t, headers.Get("Content-Type"), "application/json")
+				assert.StringContains(t, body, tt.wantBody)
+			} else {
+				assert.Equal(t, headers.Get("Content-Type"), "application/problem+json")
+				assert.Equal(t, decodeProblem(t, body).Status, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestAPISnippetCreate(t *testing.T) {
+	app := newTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	const validBody = `{"title": "A new title", "content": "An old silent pond...", "expires": 7}`
+
+	t.Run("Unauthenticated", func(t *testing.T) {
+		code, _, _ := ts.sendJSON(t, http.MethodPost, "/api/v1/snippets", "", validBody)
+
+		assert.Equal(t, code, http.StatusUnauthorized)
+	})
+
+	ts.login(t)
+
+	tests := []struct {
+		name       string
+		body       string
+		wantCode   int
+		wantErrors map[string]string
+	}{
+		{
+			name:     "Valid body",
+			body:     validBody,
+			wantCode: http.StatusCreated,
+		},
+		{
+			name:       "Invalid fields",
+			body:       `{"title": "", "content": "An old silent pond...", "visibility": "secret"}`,
+			wantCode:   http.StatusUnprocessableEntity,
+			wantErrors: map[string]string{"title": "This field cannot be blank", "visibility": "This field must equal public, unlisted or private"},
+		},
+		{
+			name:     "Tags",
+			body:     `{"title": "A new title", "content": "An old silent pond...", "tags": ["haiku", "Nature"]}`,
+			wantCode: http.StatusCreated,
+		},
+		{
+			name:       "Invalid tags",
+			body:       `{"title": "A new title", "content": "An old silent pond...", "tags": ["haiku", "c#"]}`,
+			wantCode:   http.StatusUnprocessableEntity,
+			wantErrors: map[string]string{"tags": "Tags can only contain letters, numbers and dashes"},
+		},
+		{
+			name:     "Badly-formed JSON",
+			body:     `{"title": "A new title"`,
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "Unknown field",
+			body:     `{"title": "A new title", "author": "Bob"}`,
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "Wrong type",
+			body:     `{"title": "A new title", "expires": true}`,
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "Empty body",
+			body:     "",
+			wantCode: http.StatusUnsupportedMediaType,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, headers, body := ts.sendJSON(t, http.MethodPost, "/api/v1/snippets", "", tt.body)
+
+			assert.Equal(t, code, tt.wantCode)
+
+			if code == http.StatusCreated {
+				assert.Equal(t, headers.Get("Location"), "/api/v1/snippets/new5HjKmNp")
+				return
+			}
+
+			p := decodeProblem(t, body)
+			assert.Equal(t, p.Status, tt.wantCode)
+			for field, message := range tt.wantErrors {
+				assert.Equal(t, p.Errors[field], message)
+			}
+		})
+	}
+}
+
+func TestAPISnippetUpdate(t *testing.T) {
+	app := newTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		body     string
+		wantCode int
+	}{
+		{
+			name:     "Owner",
+			urlPath:  "/api/v1/snippets/pond4Xk9Qa",
+			body:     `{"title": "A new title"}`,
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "Not owner",
+			urlPath:  "/api/v1/snippets/forest7BcD",
+			body:     `{"title": "A new title"}`,
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "Invalid field",
+			urlPath:  "/api/v1/snippets/pond4Xk9Qa",
+			body:     `{"format": "html"}`,
+			wantCode: http.StatusUnprocessableEntity,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, _ := ts.sendJSON(t, http.MethodPatch, tt.urlPath, "", tt.body)
+
+			assert.Equal(t, code, tt.wantCode)
+		})
+	}
+}
+
+// updateRecordingSnippetModel keeps the expiry each snippet was last
+// updated with.
+type updateRecordingSnippetModel struct {
+	mocks.SnippetModel
+	expires       map[int]time.Time
+	burnAfterRead map[int]bool
+}
+
+func (m *updateRecordingSnippetModel) Update(ctx context.Context, id int, userID int, title string, content string, visibility string, language string, format string, tags []string, expires time.Time, burnAfterRead bool) error {
+	m.expires[id] = expires
+	m.burnAfterRead[id] = burnAfterRead
+	return m.SnippetModel.Update(ctx, id, userID, title, content, visibility, language, format, tags, expires, burnAfterRead)
+}
+
+func TestAPISnippetUpdateExpiry(t *testing.T) {
+	app := newTestApplication(t)
+
+	snippets := &updateRecordingSnippetModel{expires: map[int]time.Time{}, burnAfterRead: map[int]bool{}}
+	app.snippets = snippets
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+
+	snippet, err := snippets.Peek(context.Background(), "pond4Xk9Qa")
+	assert.NilError(t, err)
+
+	t.Run("Left out", func(t *testing.T) {
+		code, _, _ := ts.sendJSON(t, http.MethodPatch, "/api/v1/snippets/pond4Xk9Qa", "", `{"title": "A new title"}`)
+
+		assert.Equal(t, code, http.StatusOK)
+		assert.Equal(t, snippets.expires[snippet.ID].Equal(snippet.Expires), true)
+		assert.Equal(t, snippets.burnAfterRead[snippet.ID], false)
+	})
+
+	t.Run("Changed", func(t *testing.T) {
+		code, _, _ := ts.sendJSON(t, http.MethodPatch, "/api/v1/snippets/pond4Xk9Qa", "", `{"expires": "1h"}`)
+
+		assert.Equal(t, code, http.StatusOK)
+		assert.Equal(t, snippets.expires[snippet.ID].Before(time.Now().Add(2*time.Hour)), true)
+	})
+}
+
+func TestAPISnippetDelete(t *testing.T) {
+	app := newTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	t.Run("Unauthenticated", func(t *testing.T) {
+		code, _, _ := ts.sendJSON(t, http.MethodDelete, "/api/v1/snippets/pond4Xk9Qa", "", "")
+
+		assert.Equal(t, code, http.StatusUnauthorized)
+	})
+
+	ts.login(t)
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+	}{
+		{
+			name:     "Owner",
+			urlPath:  "/api/v1/snippets/pond4Xk9Qa",
+			wantCode: http.StatusNoContent,
+		},
+		{
+			name:     "Not owner",
+			urlPath:  "/api/v1/snippets/forest7BcD",
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "Non-existent slug",
+			urlPath:  "/api/v1/snippets/zzzzzzzzzz",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, _ := ts.sendJSON(t, http.MethodDelete, tt.urlPath, "", "")
+
+			assert.Equal(t, code, tt.wantCode)
+		})
+	}
+}
+
+func decodeProblem(t *testing.T, body string) problem {
+	var p problem
+	err := json.Unmarshal([]byte(body), &p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestAPITokenAuthentication(t *testing.T) {
+	app := newTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	const body = `{"title": "A new title", "content": "An old silent pond..."}`
+
+	tests := []struct {
+		name     string
+		token    string
+		wantCode int
+	}{
+		{
+			name:     "Write token",
+			token:    mocks.WriteToken,
+			wantCode: http.StatusCreated,
+		},
+		{
+			name:     "Read token",
+			token:    mocks.ReadToken,
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "Invalid token",
+			token:    "sbx_wrong",
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name:     "No token",
+			wantCode: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, headers, _ := ts.sendJSON(t, http.MethodPost, "/api/v1/snippets", tt.token, body)
+
+			assert.Equal(t, code, tt.wantCode)
+
+			if code == http.StatusUnauthorized {
+				assert.StringContains(t, headers.Get("WWW-Authenticate"), "Bearer")
+			}
+		})
+	}
+}
+
+func TestAPISnippetCreateUnverified(t *testing.T) {
+	app := newTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.loginAs(t, "carol@example.com")
+
+	code, _, body := ts.sendJSON(t, http.MethodPost, "/api/v1/snippets", "", `{"title": "A new title", "content": "An old silent pond..."}`)
+
+	assert.Equal(t, code, http.StatusForbidden)
+	assert.Equal(t, decodeProblem(t, body).Detail, "Verify your email address before creating snippets")
+}
+
+func TestAPIUserSnippets(t *testing.T) {
+	app := newTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	t.Run("Unauthenticated", func(t *testing.T) {
+		code, _, _ := ts.sendJSON(t, http.MethodGet, "/api/v1/user/snippets", "", "")
+
+		assert.Equal(t, code, http.StatusUnauthorized)
+	})
+
+	t.Run("Read token", func(t *testing.T) {
+		code, _, body := ts.sendJSON(t, http.MethodGet, "/api/v1/user/snippets", mocks.ReadToken, "")
+
+		assert.Equal(t, code, http.StatusOK)
+		assert.StringContains(t, body, `"id":"pond4Xk9Qa"`)
+	})
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/mail"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// config holds the server's settings. Each can be given in a YAML file, in a
+// SNIPPETBOX_* environment variable or as a command-line flag, and later ones
+// in that list override earlier ones. The environment variable for a flag is
+// its name in upper case with dashes turned into underscores, so -tls-cert
+// can be set with SNIPPETBOX_TLS_CERT, and the YAML key is the same in lower
+// case.
+type config struct {
+	Addr              string        `yaml:"addr"`
+	DSN               string        `yaml:"dsn"`
+	Debug             bool          `yaml:"debug"`
+	LogFormat         string        `yaml:"log_format"`
+	MetricsAddr       string        `yaml:"metrics_addr"`
+	TLSCert           string        `yaml:"tls_cert"`
+	TLSKey            string        `yaml:"tls_key"`
+	SessionLifetime   time.Duration `yaml:"session_lifetime"`
+	IdleTimeout       time.Duration `yaml:"idle_timeout"`
+	ReadTimeout       time.Duration `yaml:"read_timeout"`
+	WriteTimeout      time.Duration `yaml:"write_timeout"`
+	DrainTimeout      time.Duration `yaml:"drain_timeout"`
+	ReapInterval      time.Duration `yaml:"reap_interval"`
+	QueryTimeout      time.Duration `yaml:"query_timeout"`
+	CacheSize         int           `yaml:"cache_size"`
+	CacheTTL          time.Duration `yaml:"cache_ttl"`
+	AllowNeverExpires bool          `yaml:"allow_never_expires"`
+	Migrate           bool          `yaml:"migrate"`
+	BaseURL           string        `yaml:"base_url"`
+	VerifySecret      string        `yaml:"verify_secret"`
+	SMTPAddr          string        `yaml:"smtp_addr"`
+	SMTPUsername      string        `yaml:"smtp_username"`
+	SMTPPassword      string        `yaml:"smtp_password"`
+	SMTPTimeout       time.Duration `yaml:"smtp_timeout"`
+	MailFrom          string        `yaml:"mail_from"`
+	MailFile          string        `yaml:"mail_file"`
+}
+
+// envPrefix starts the name of every environment variable read by
+// loadConfig.
+const envPrefix = "SNIPPETBOX_"
+
+// errUsage is returned by loadConfig when the command line was wrong. The
+// usage has already been printed, so there's nothing more to say about it.
+var errUsage = errors.New("usage")
+
+// commands are the subcommands which can be given instead of running the
+// server.
+var commands = []string{"purge", "migrate up", "migrate down", "migrate status"}
+
+// loadConfig works out the settings from the command-line arguments, the
+// environment, as read through getenv, and the config file if one is named
+// by the -config flag or SNIPPETBOX_CONFIG. It also returns the subcommand,
+// such as "migrate up", which is empty when the server should be run.
+//
+// Usage and flag errors are written to output. Any problems with the
+// settings themselves are returned together as one error.
+func loadConfig(args []string, getenv func(string) (string, bool), output io.Writer) (*config, string, error) {
+	cfg := &config{
+		Addr:              ":4000",
+		DSN:               "web:pass@/snippetbox?parseTime=true",
+		LogFormat:         "text",
+		TLSCert:           "./tls/cert.pem",
+		TLSKey:            "./tls/key.pem",
+		SessionLifetime:   12 * time.Hour,
+		IdleTimeout:       time.Minute,
+		ReadTimeout:       5 * time.Second,
+		WriteTimeout:      10 * time.Second,
+		DrainTimeout:      20 * time.Second,
+		ReapInterval:      time.Hour,
+		QueryTimeout:      3 * time.Second,
+		CacheSize:         1000,
+		CacheTTL:          30 * time.Second,
+		AllowNeverExpires: true,
+		BaseURL:           "https://localhost:4000",
+		SMTPTimeout:       5 * time.Second,
+		MailFrom:          "Snippetbox <no-reply@localhost>",
+	}
+
+	fs := flag.NewFlagSet("web", flag.ContinueOnError)
+	fs.SetOutput(output)
+	fs.Usage = func() {
+		fmt.Fprintln(output, "usage: web [flags] [purge | migrate up|down|status]")
+		fmt.Fprintln(output)
+		fmt.Fprintln(output, "With purge, expired snippets are deleted and the server isn't started.")
+		fmt.Fprintln(output, "With migrate, the schema is migrated up to date, the latest migration is")
+		fmt.Fprintln(output, "reverted, or the migrations are listed.")
+		fmt.Fprintln(output, "Every flag can also be set in the config file or with a SNIPPETBOX_* environment variable.")
+		fmt.Fprintln(output)
+		fs.PrintDefaults()
+	}
+
+	configFile := fs.String("config", "", "YAML config `file`")
+	fs.StringVar(&cfg.Addr, "addr", cfg.Addr, "HTTP network address")
+	fs.StringVar(&cfg.DSN, "dsn", cfg.DSN, "Data source name: a MySQL DSN, which needs parseTime=true, mysql://... (which adds it), postgres://... or sqlite:path/to/file.db")
+	fs.BoolVar(&cfg.Debug, "debug", cfg.Debug, "debug mode")
+	fs.StringVar(&cfg.LogFormat, "log-format", cfg.LogFormat, "log output `format`, text or json")
+	fs.StringVar(&cfg.MetricsAddr, "metrics-addr", cfg.MetricsAddr, "separate plain HTTP address to serve /metrics on, rather than with the site")
+	fs.StringVar(&cfg.TLSCert, "tls-cert", cfg.TLSCert, "TLS certificate `file`")
+	fs.StringVar(&cfg.TLSKey, "tls-key", cfg.TLSKey, "TLS private key `file`")
+	fs.DurationVar(&cfg.SessionLifetime, "session-lifetime", cfg.SessionLifetime, "how long a login lasts")
+	fs.DurationVar(&cfg.IdleTimeout, "idle-timeout", cfg.IdleTimeout, "how long to keep idle connections open")
+	fs.DurationVar(&cfg.ReadTimeout, "read-timeout", cfg.ReadTimeout, "how long to wait for a request to be read")
+	fs.DurationVar(&cfg.WriteTimeout, "write-timeout", cfg.WriteTimeout, "how long a response may take to write")
+	fs.DurationVar(&cfg.DrainTimeout, "drain-timeout", cfg.DrainTimeout, "how long to let in-flight requests finish when shutting down")
+	fs.DurationVar(&cfg.ReapInterval, "reap-interval", cfg.ReapInterval, "how often to delete expired snippets, or 0 to leave them")
+	fs.DurationVar(&cfg.QueryTimeout, "query-timeout", cfg.QueryTimeout, "how long a request may spend on each call to the database, or 0 for no limit")
+	fs.IntVar(&cfg.CacheSize, "cache-size", cfg.CacheSize, "how many snippets to cache in memory, or 0 to turn the cache off")
+	fs.DurationVar(&cfg.CacheTTL, "cache-ttl", cfg.CacheTTL, "how long a cached snippet may be served before it's looked up again")
+	fs.BoolVar(&cfg.AllowNeverExpires, "allow-never-expires", cfg.AllowNeverExpires, "let snippets be kept forever")
+	fs.BoolVar(&cfg.Migrate, "migrate", cfg.Migrate, "apply pending schema migrations at startup")
+	fs.StringVar(&cfg.BaseURL, "base-url", cfg.BaseURL, "`URL` the site is reached at, for links in emails")
+	fs.StringVar(&cfg.VerifySecret, "verify-secret", cfg.VerifySecret, "key for signing email verification links, at least 32 characters; a random one is used if empty, so links stop working on restart")
+	fs.StringVar(&cfg.SMTPAddr, "smtp-addr", cfg.SMTPAddr, "host and port of the SMTP server to send email through")
+	fs.StringVar(&cfg.SMTPUsername, "smtp-username", cfg.SMTPUsername, "username for the SMTP server")
+	fs.StringVar(&cfg.SMTPPassword, "smtp-password", cfg.SMTPPassword, "password for the SMTP server")
+	fs.DurationVar(&cfg.SMTPTimeout, "smtp-timeout", cfg.SMTPTimeout, "how long sending an email may take, which must be less than write-timeout as signup waits for it")
+	fs.StringVar(&cfg.MailFrom, "mail-from", cfg.MailFrom, "sender `address` of emails")
+	fs.StringVar(&cfg.MailFile, "mail-file", cfg.MailFile, "without an SMTP server, append emails to this `file` rather than logging them")
+
+	err := fs.Parse(args)
+	if errors.Is(err, flag.ErrHelp) {
+		return nil, "", err
+	}
+	if err != nil {
+		return nil, "", errUsage
+	}
+
+	command := strings.Join(fs.Args(), " ")
+	if command != "" && !slices.Contains(commands, command) {
+		fs.Usage()
+		return nil, "", errUsage
+	}
+
+	// The flags have already been written into cfg, but have to win over
+	// the file and environment, so remember them to set again at the end
+	flags := map[string]string{}
+	fs.Visit(func(f *flag.Flag) {
+		flags[f.Name] = f.Value.String()
+	})
+
+	if *configFile == "" {
+		*configFile, _ = getenv(envPrefix + "CONFIG")
+	}
+	if *configFile != "" {
+		err := cfg.readFile(*configFile)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+
+	var errs []error
+	fs.VisitAll(func(f *flag.Flag) {
+		if f.Name == "config" {
+			return
+		}
+		name := envPrefix + strings.ToUpper(strings.ReplaceAll(f.Name, "-", "_"))
+		if value, ok := getenv(name); ok {
+			err := fs.Set(f.Name, value)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", name, err))
+			}
+		}
+	})
+	for name, value := range flags {
+		fs.Set(name, value)
+	}
+
+	errs = append(errs, cfg.validate()...)
+	if len(errs) > 0 {
+		return nil, "", errors.Join(errs...)
+	}
+
+	return cfg, command, nil
+}
+
+// readFile overrides the settings with those in a YAML file. Unknown keys are
+// an error, so that typos don't go unnoticed.
+func (cfg *config) readFile(path string) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	dec := yaml.NewDecoder(bytes.NewReader(b))
+	dec.KnownFields(true)
+
+	err = dec.Decode(cfg)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// validate checks the settings make sense, returning every problem found.
+func (cfg *config) validate() []error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	_, _, err := net.SplitHostPort(cfg.Addr)
+	check(err == nil, "addr: %q must be a host and port, such as :4000", cfg.Addr)
+	if cfg.MetricsAddr != "" {
+		_, _, err := net.SplitHostPort(cfg.MetricsAddr)
+		check(err == nil, "metrics-addr: %q must be a host and port, such as localhost:9090", cfg.MetricsAddr)
+	}
+	check(cfg.DSN != "", "dsn: must not be empty")
+	check(cfg.LogFormat == "text" || cfg.LogFormat == "json", "log-format: %q must be text or json", cfg.LogFormat)
+	check(cfg.TLSCert != "", "tls-cert: must not be empty")
+	check(cfg.TLSKey != "", "tls-key: must not be empty")
+	check(cfg.SessionLifetime > 0, "session-lifetime: must be more than 0")
+	check(cfg.IdleTimeout > 0, "idle-timeout: must be more than 0")
+	check(cfg.ReadTimeout > 0, "read-timeout: must be more than 0")
+	check(cfg.WriteTimeout > 0, "write-timeout: must be more than 0")
+	check(cfg.DrainTimeout >= 0, "drain-timeout: must not be negative")
+	check(cfg.ReapInterval >= 0, "reap-interval: must not be negative")
+	check(cfg.QueryTimeout >= 0, "query-timeout: must not be negative")
+	check(cfg.CacheSize >= 0, "cache-size: must not be negative")
+	check(cfg.CacheTTL > 0, "cache-ttl: must be more than 0")
+
+	u, err := url.Parse(cfg.BaseURL)
+	check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "" && u.RawQuery == "" && u.Fragment == "",
+		"base-url: %q must be an http or https URL, such as https://snippetbox.example.com", cfg.BaseURL)
+	check(cfg.VerifySecret == "" || len(cfg.VerifySecret) >= 32, "verify-secret: must be at least 32 characters")
+	if cfg.SMTPAddr != "" {
+		_, _, err := net.SplitHostPort(cfg.SMTPAddr)
+		check(err == nil, "smtp-addr: %q must be a host and port, such as smtp.example.com:587", cfg.SMTPAddr)
+	}
+	check(cfg.SMTPTimeout > 0 && cfg.SMTPTimeout < cfg.WriteTimeout, "smtp-timeout: must be more than 0 and less than write-timeout")
+	_, err = mail.ParseAddress(cfg.MailFrom)
+	check(err == nil, "mail-from: %q must be an email address", cfg.MailFrom)
+
+	return errs
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"snippetbox.cozycole.net/internal/assert"
+)
+
+// fakeEnv looks variables up in a map rather than the real environment.
+func fakeEnv(vars map[string]string) func(string) (string, bool) {
+	return func(name string) (string, bool) {
+		value, ok := vars[name]
+		return value, ok
+	}
+}
+
+func writeConfigFile(t *testing.T, contents string) string {
+	path := filepath.Join(t.TempDir(), "snippetbox.yaml")
+
+	err := os.WriteFile(path, []byte(contents), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfig(t *testing.T) {
+	path := writeConfigFile(t, `
+addr: ":5000"
+dsn: file:pass@/snippetbox
+read_timeout: 3s
+write_timeout: 30s
+debug: true
+`)
+
+	env := map[string]string{
+		"SNIPPETBOX_CONFIG":        path,
+		"SNIPPETBOX_ADDR":          ":6000",
+		"SNIPPETBOX_WRITE_TIMEOUT": "45s",
+		"SNIPPETBOX_TLS_CERT":      "/etc/snippetbox/cert.pem",
+	}
+
+	cfg, command, err := loadConfig([]string{"-addr", ":7000", "purge"}, fakeEnv(env), io.Discard)
+	assert.NilError(t, err)
+	assert.Equal(t, command, "purge")
+
+	// Flags beat the environment, which beats the file, which beats the
+	// defaults
+	assert.Equal(t, cfg.Addr, ":7000")
+	assert.Equal(t, cfg.WriteTimeout, 45*time.Second)
+	assert.Equal(t, cfg.TLSCert, "/etc/snippetbox/cert.pem")
+	assert.Equal(t, cfg.DSN, "file:pass@/snippetbox")
+	assert.Equal(t, cfg.ReadTimeout, 3*time.Second)
+	assert.Equal(t, cfg.Debug, true)
+	assert.Equal(t, cfg.TLSKey, "./tls/key.pem")
+	assert.Equal(t, cfg.SessionLifetime, 12*time.Hour)
+	assert.Equal(t, cfg.BaseURL, "https://localhost:4000")
+	assert.Equal(t, cfg.MailFrom, "Snippetbox <no-reply@localhost>")
+	assert.Equal(t, cfg.SMTPTimeout, 5*time.Second)
+
+	t.Run("Config flag", func(t *testing.T) {
+		other := writeConfigFile(t, `addr: ":8000"`)
+
+		cfg, command, err := loadConfig([]string{"-config", other}, fakeEnv(map[string]string{"SNIPPETBOX_CONFIG": path}), io.Discard)
+		assert.NilError(t, err)
+		assert.Equal(t, command, "")
+		assert.Equal(t, cfg.Addr, ":8000")
+		assert.Equal(t, cfg.Debug, false)
+	})
+
+	t.Run("Migrate", func(t *testing.T) {
+		cfg, command, err := loadConfig([]string{"migrate", "status"}, fakeEnv(map[string]string{"SNIPPETBOX_MIGRATE": "true"}), io.Discard)
+		assert.NilError(t, err)
+		assert.Equal(t, command, "migrate status")
+		assert.Equal(t, cfg.Migrate, true)
+	})
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		env  map[string]string
+		file string
+		want string
+	}{
+		{
+			name: "Invalid values",
+			args: []string{"-addr", "4000", "-session-lifetime", "0s"},
+			env:  map[string]string{"SNIPPETBOX_DSN": ""},
+			want: "addr: \"4000\" must be a host and port, such as :4000\ndsn: must not be empty\nsession-lifetime: must be more than 0",
+		},
+		{
+			name: "Invalid mail settings",
+			args: []string{"-base-url", "snippetbox.example.com", "-verify-secret", "short", "-smtp-addr", "smtp.example.com", "-smtp-timeout", "10s"},
+			env:  map[string]string{"SNIPPETBOX_MAIL_FROM": "nobody"},
+			want: "base-url: \"snippetbox.example.com\" must be an http or https URL, such as https://snippetbox.example.com\nverify-secret: must be at least 32 characters\nsmtp-addr: \"smtp.example.com\" must be a host and port, such as smtp.example.com:587\nsmtp-timeout: must be more than 0 and less than write-timeout\nmail-from: \"nobody\" must be an email address",
+		},
+		{
+			name: "Bad environment variable",
+			env:  map[string]string{"SNIPPETBOX_READ_TIMEOUT": "soon"},
+			want: "SNIPPETBOX_READ_TIMEOUT: parse error",
+		},
+		{
+			name: "Unknown key in file",
+			file: "adr: \":4000\"\n",
+			want: "field adr not found",
+		},
+		{
+			name: "Bad duration in file",
+			file: "idle_timeout: forever\n",
+			want: "cannot unmarshal",
+		},
+		{
+			name: "Unknown command",
+			args: []string{"serve"},
+			want: "usage",
+		},
+		{
+			name: "Unknown migrate command",
+			args: []string{"migrate", "sideways"},
+			want: "usage",
+		},
+		{
+			name: "Extra argument",
+			args: []string{"purge", "now"},
+			want: "usage",
+		},
+		{
+			name: "Unknown flag",
+			args: []string{"-port", "4000"},
+			want: "usage",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := tt.env
+			if tt.file != "" {
+				env = map[string]string{"SNIPPETBOX_CONFIG": writeConfigFile(t, tt.file)}
+			}
+
+			_, _, err := loadConfig(tt.args, fakeEnv(env), io.Discard)
+			if err == nil {
+				t.Fatal("got: nil; want: an error")
+			}
+			assert.StringContains(t, err.Error(), tt.want)
+		})
+	}
+
+	t.Run("Help", func(t *testing.T) {
+		_, _, err := loadConfig([]string{"-h"}, fakeEnv(nil), io.Discard)
+		assert.Equal(t, errors.Is(err, flag.ErrHelp), true)
+	})
+}
@@ -2,4 +2,12 @@ package main
 
 type contextKey string
 
-const isAutheticatedContextKey = contextKey("isAuthenticated")
+const (
+	isAutheticatedContextKey      = contextKey("isAuthenticated")
+	authenticatedUserIDContextKey = contextKey("authenticatedUserID")
+	requestIDContextKey           = contextKey("requestID")
+	routeContextKey               = contextKey("route")
+	// Only set when the request was authenticated with a personal access
+	// token rather than the session
+	tokenContextKey = contextKey("token")
+)
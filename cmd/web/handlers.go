@@ -1,11 +1,20 @@
 package main
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"mime"
 	"net/http"
+	"slices"
 	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
 
+	"snippetbox.cozycole.net/internal/diff"
+	"snippetbox.cozycole.net/internal/highlight"
 	"snippetbox.cozycole.net/internal/models"
 	"snippetbox.cozycole.net/internal/validator"
 
@@ -14,61 +23,485 @@ import (
 
 func (app *application) home(w http.ResponseWriter, r *http.Request) {
 
-	snippets, err := app.snippets.Latest()
+	snippets, err := app.snippets.Latest(r.Context())
 	if err != nil {
-		app.serverError(w, err)
+		app.serverError(w, r, err)
+		return
+	}
+
+	tags, err := app.snippets.TagCloud(r.Context(), tagCloudSize)
+	if err != nil {
+		app.serverError(w, r, err)
 		return
 	}
 
 	data := app.newTemplateData(r)
 	data.Snippets = snippets
+	data.TagCloud = newTagCloud(tags)
 
-	app.render(w, http.StatusOK, "home.tmpl.html", data)
+	app.render(w, r, http.StatusOK, "home.tmpl.html", data)
 }
 
-func (app *application) about(w http.ResponseWriter, r *http.Request) {
+// browsePageSize is how many snippets are shown on each page when browsing
+// through all of them or the archive.
+const browsePageSize = 20
+
+func (app *application) snippetBrowse(w http.ResponseWriter, r *http.Request) {
+	before, ok := app.cursorParam(w, r)
+	if !ok {
+		return
+	}
+
+	// Ask for one more than a page to find out if there's another one
+	snippets, err := app.snippets.Browse(r.Context(), before, browsePageSize+1)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
 	data := app.newTemplateData(r)
-	app.render(w, http.StatusOK, "about.tmpl.html", data)
+	data.Snippets, data.Browse = newBrowsePage(r, "All Snippets", snippets)
+
+	app.render(w, r, http.StatusOK, "browse.tmpl.html", data)
 }
 
-func (app *application) snippetView(w http.ResponseWriter, r *http.Request) {
+func (app *application) archive(w http.ResponseWriter, r *http.Request) {
+	months, err := app.snippets.ArchiveMonths(r.Context())
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Months = months
+
+	app.render(w, r, http.StatusOK, "archive.tmpl.html", data)
+}
 
+func (app *application) archiveMonth(w http.ResponseWriter, r *http.Request) {
 	params := httprouter.ParamsFromContext(r.Context())
 
-	id, err := strconv.Atoi(params.ByName("id"))
-	if err != nil || id < 1 {
+	year, err := strconv.Atoi(params.ByName("year"))
+	if err != nil || year < 1 || year > 9999 {
+		app.notFound(w)
+		return
+	}
+	month, err := strconv.Atoi(params.ByName("month"))
+	if err != nil || month < 1 || month > 12 {
 		app.notFound(w)
 		return
 	}
 
-	snippet, err := app.snippets.Get(id)
+	before, ok := app.cursorParam(w, r)
+	if !ok {
+		return
+	}
+
+	start := time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.UTC)
+	snippets, err := app.snippets.Archive(r.Context(), start, before, browsePageSize+1)
 	if err != nil {
-		if errors.Is(err, models.ErrNoRecord) {
-			app.notFound(w)
-		} else {
-			app.serverError(w, err)
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Snippets, data.Browse = newBrowsePage(r, "Snippets from "+start.Format("January 2006"), snippets)
+
+	app.render(w, r, http.StatusOK, "browse.tmpl.html", data)
+}
+
+func (app *application) snippetsTagged(w http.ResponseWriter, r *http.Request) {
+	tag := httprouter.ParamsFromContext(r.Context()).ByName("tag")
+	if !validTag(tag) {
+		app.notFound(w)
+		return
+	}
+
+	before, ok := app.cursorParam(w, r)
+	if !ok {
+		return
+	}
+
+	snippets, err := app.snippets.ByTag(r.Context(), tag, before, browsePageSize+1)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Snippets, data.Browse = newBrowsePage(r, "Snippets tagged "+tag, snippets)
+
+	app.render(w, r, http.StatusOK, "browse.tmpl.html", data)
+}
+
+func (app *application) about(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	app.render(w, r, http.StatusOK, "about.tmpl.html", data)
+}
+
+func (app *application) search(w http.ResponseWriter, r *http.Request) {
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+
+	page := 1
+	if p := r.URL.Query().Get("page"); p != "" {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 1 {
+			app.clientError(w, http.StatusBadRequest)
+			return
 		}
+		page = n
+	}
+
+	data := app.newTemplateData(r)
+	data.Search = &searchPage{Query: query, Page: page}
+
+	// With no query just show the search form
+	if query == "" {
+		app.render(w, r, http.StatusOK, "search.tmpl.html", data)
+		return
+	}
+
+	if utf8.RuneCountInString(query) > 200 {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	snippets, more, err := app.snippets.Search(r.Context(), query, page)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data.Snippets = snippets
+	if page > 1 {
+		data.Search.PrevPage = page - 1
+	}
+	if more {
+		data.Search.NextPage = page + 1
+	}
+
+	app.render(w, r, http.StatusOK, "search.tmpl.html", data)
+}
+
+func (app *application) snippetView(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.requestedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Snippet = snippet
+
+	app.render(w, r, http.StatusOK, "view.tmpl.html", data)
+}
+
+func (app *application) snippetHistory(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.requestedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	revisions, err := app.snippets.Revisions(r.Context(), snippet.ID)
+	if err != nil {
+		app.serverError(w, r, err)
 		return
 	}
 
 	data := app.newTemplateData(r)
 	data.Snippet = snippet
+	data.Revisions = revisions
+
+	// Compare the two most recent revisions unless the ?from= and ?to=
+	// query parameters pick others
+	if len(revisions) >= 2 {
+		from, to := revisions[1], revisions[0]
+
+		query := r.URL.Query()
+		if query.Has("from") {
+			from = findRevision(revisions, query.Get("from"))
+		}
+		if query.Has("to") {
+			to = findRevision(revisions, query.Get("to"))
+		}
+		if from == nil || to == nil {
+			app.notFound(w)
+			return
+		}
 
-	app.render(w, http.StatusOK, "view.tmpl.html", data)
+		data.Diff = &snippetDiff{
+			From:  from,
+			To:    to,
+			Hunks: diff.Unified(from.Content, to.Content, 3),
+		}
+	}
+
+	app.render(w, r, http.StatusOK, "history.tmpl.html", data)
+}
+
+// findRevision returns the revision with the given number, or nil if there
+// isn't one.
+func findRevision(revisions []*models.SnippetRevision, number string) *models.SnippetRevision {
+	n, err := strconv.Atoi(number)
+	if err != nil {
+		return nil
+	}
+	for _, r := range revisions {
+		if r.Revision == n {
+			return r
+		}
+	}
+	return nil
+}
+
+// snippetRaw serves just the snippet's content as plain text, for piping
+// into a shell or saving to a file with curl.
+func (app *application) snippetRaw(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.requestedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	app.serveSnippetContent(w, r, snippet)
+}
+
+// snippetDownload serves the snippet's content like snippetRaw, but asks the
+// browser to save it under a filename made from the title and language.
+func (app *application) snippetDownload(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.requestedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	disposition := mime.FormatMediaType("attachment", map[string]string{"filename": downloadFilename(snippet)})
+	w.Header().Set("Content-Disposition", disposition)
+
+	app.serveSnippetContent(w, r, snippet)
 }
 
 // Include struct tags which tell the decoder how to map HTML form values
 // into the different struct field. For example, here we're telling the decoder
 // to store the value from the HTML form input with the name "title" in the Title field. The struct tag `form:"-`
-// tells the decoder to completely ignore a field during decoding.
+// tells the decoder to completely ignore a field during decoding. The json tags
+// do the same for snippets sent to the API.
 type snippetCreateForm struct {
-	Title   string `form:"title"`
-	Content string `form:"content"`
-	Expires int    `form:"expires"`
+	Title      string   `form:"title" json:"title"`
+	Content    string   `form:"content" json:"content"`
+	Visibility string   `form:"visibility" json:"visibility"`
+	Language   string   `form:"language" json:"language"`
+	Format     string   `form:"format" json:"format"`
+	Tags       []string `form:"tags" json:"tags"`
+	Expires    expiry   `form:"expires" json:"expires"`
+	ExpiresAt  string   `form:"expires_at" json:"expires_at"`
 	// adds the validator package as an attribute
 	// meaning public functions of validator.Validator
 	// act as methods
-	validator.Validator `form:"-"`
+	validator.Validator `form:"-" json:"-"`
+}
+
+// validate runs the checks shared by the create and edit snippet forms.
+// allowNever is whether snippets may be kept forever.
+func (form *snippetCreateForm) validate(allowNever bool) {
+	// Since the Validator type is embedded in the snippetCreateForm, we can
+	// call CheckField directly on the object.
+	form.CheckField(validator.NotBlank(form.Title), "title", "This field cannot be blank")
+	form.CheckField(validator.MaxChars(form.Title, 100), "title", "This field cannot be more than 100 characters long")
+	form.CheckField(validator.NotBlank(form.Content), "content", "This field cannot be blank")
+	form.CheckField(
+		validator.PermittedValue(form.Visibility, models.VisibilityPublic, models.VisibilityUnlisted, models.VisibilityPrivate),
+		"visibility",
+		"This field must equal public, unlisted or private",
+	)
+	form.CheckField(
+		form.Language == highlight.Auto || validator.PermittedValue(form.Language, highlight.Names()...),
+		"language",
+		"This field must be a supported language",
+	)
+	form.CheckField(
+		validator.PermittedValue(form.Format, models.FormatPlain, models.FormatMarkdown),
+		"format",
+		"This field must equal plain or markdown",
+	)
+
+	form.Expires = form.Expires.normalize()
+	switch form.Expires {
+	case expiresBurn:
+		// Listing a snippet which is deleted by the first person to click on
+		// it would be no use to anyone
+		form.CheckField(form.Visibility != models.VisibilityPublic, "visibility", "Snippets which burn after reading cannot be public")
+	case expiresNever:
+		form.CheckField(allowNever, "expires", "Snippets must expire on this server")
+	case expiresCustom:
+		t, err := parseExpiresAt(form.ExpiresAt)
+		if err != nil {
+			form.AddFieldError("expires_at", "This field must be a date and time")
+			break
+		}
+		form.CheckField(t.After(time.Now()), "expires_at", "This field must be in the future")
+		form.CheckField(t.Before(time.Now().Add(maxCustomExpiry)), "expires_at", "This field must be within a year")
+	default:
+		_, ok := expiryDurations[form.Expires]
+		form.CheckField(ok, "expires", "This field must be one of the expiry options")
+	}
+
+	form.Tags = normalizeTags(form.Tags)
+	form.CheckField(validator.MaxItems(form.Tags, maxTags), "tags", fmt.Sprintf("This field cannot have more than %d tags", maxTags))
+	for _, tag := range form.Tags {
+		form.CheckField(validator.MaxChars(tag, maxTagLength), "tags", fmt.Sprintf("Tags cannot be more than %d characters long", maxTagLength))
+		form.CheckField(validator.PermittedChars(tag, tagChars), "tags", "Tags can only contain letters, numbers and dashes")
+	}
+}
+
+// Tags are short words, which may be joined with dashes, so that they read
+// well in /tags/ URLs. They're stored in lowercase.
+const (
+	maxTags      = 5
+	maxTagLength = 30
+	tagChars     = "abcdefghijklmnopqrstuvwxyz0123456789-"
+)
+
+// normalizeTags splits the tags as entered, which may be separated by commas
+// or spaces, and lowercases them and drops any duplicates.
+func normalizeTags(values []string) []string {
+	tags := []string{}
+	for _, value := range values {
+		for _, tag := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || unicode.IsSpace(r) }) {
+			tag = strings.ToLower(tag)
+			if !slices.Contains(tags, tag) {
+				tags = append(tags, tag)
+			}
+		}
+	}
+	return tags
+}
+
+// validTag reports whether tag could have been saved on a snippet.
+func validTag(tag string) bool {
+	return tag != "" && validator.MaxChars(tag, maxTagLength) && validator.PermittedChars(tag, tagChars)
+}
+
+// Expiry options for the snippet form besides those in expiryDurations.
+const (
+	expiresBurn   expiry = "burn"
+	expiresNever  expiry = "never"
+	expiresCustom expiry = "custom"
+	// expiresKeep keeps an edited snippet's expiry as it is.
+	expiresKeep expiry = "keep"
+)
+
+// expiryDurations holds how long snippets are kept for each of the fixed
+// expiry options.
+var expiryDurations = map[expiry]time.Duration{
+	"1h":   time.Hour,
+	"6h":   6 * time.Hour,
+	"1d":   24 * time.Hour,
+	"7d":   7 * 24 * time.Hour,
+	"30d":  30 * 24 * time.Hour,
+	"365d": 365 * 24 * time.Hour,
+}
+
+const (
+	// burnExpiry is how long a snippet which burns after reading is kept if
+	// nobody reads it.
+	burnExpiry = 7 * 24 * time.Hour
+	// maxCustomExpiry is how far ahead a custom expiry time may be.
+	maxCustomExpiry = 365 * 24 * time.Hour
+)
+
+// expiry is the option picked for when a snippet is deleted. Before there
+// were options it was a number of days, so numbers are still accepted, both
+// from forms and as JSON numbers.
+type expiry string
+
+func (e *expiry) UnmarshalJSON(b []byte) error {
+	var days int
+	if json.Unmarshal(b, &days) == nil {
+		*e = expiry(strconv.Itoa(days))
+		return nil
+	}
+	return json.Unmarshal(b, (*string)(e))
+}
+
+// normalize turns a number of days into the matching option.
+func (e expiry) normalize() expiry {
+	if _, err := strconv.Atoi(string(e)); err == nil {
+		return e + "d"
+	}
+	return e
+}
+
+// parseExpiresAt reads a custom expiry time. Forms send the value of a
+// datetime-local input, which is taken to be UTC, and API clients can also
+// send an RFC 3339 time.
+func parseExpiresAt(s string) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		t, err = time.Parse("2006-01-02T15:04", s)
+	}
+	return t, err
+}
+
+// expires returns when the snippet should be deleted, counting from now, and
+// whether it should be deleted as soon as it's read. The form must already
+// have been validated.
+func (form *snippetCreateForm) expires(now time.Time) (time.Time, bool) {
+	switch form.Expires {
+	case expiresBurn:
+		return now.Add(burnExpiry).Truncate(time.Second), true
+	case expiresNever:
+		return models.NoExpiry, false
+	case expiresCustom:
+		t, _ := parseExpiresAt(form.ExpiresAt)
+		return t.Truncate(time.Second), false
+	default:
+		return now.Add(expiryDurations[form.Expires]).Truncate(time.Second), false
+	}
+}
+
+// validateEdit is validate for a form editing snippet. Its expires option
+// may be expiresKeep, or left blank, to keep the snippet's expiry as it is,
+// in which case the option matching that expiry is validated in its place
+// so that a snippet which burns after reading can't be made public.
+func (form *snippetCreateForm) validateEdit(snippet *models.Snippet, allowNever bool) {
+	if form.Expires != "" && form.Expires != expiresKeep {
+		form.validate(allowNever)
+		return
+	}
+
+	form.Expires = currentExpiry(snippet, allowNever)
+	form.validate(allowNever)
+	form.Expires = expiresKeep
+}
+
+// editExpires is expires for a form editing snippet, which has been through
+// validateEdit.
+func (form *snippetCreateForm) editExpires(snippet *models.Snippet, now time.Time) (time.Time, bool) {
+	if form.Expires == expiresKeep {
+		return snippet.Expires, snippet.BurnAfterRead
+	}
+	return form.expires(now)
+}
+
+// currentExpiry is the expiry option matching how snippet is kept now. One
+// which expires at a set time matches a year, as does one which was kept
+// forever before allowNever was turned off, so that it can still be edited.
+func currentExpiry(snippet *models.Snippet, allowNever bool) expiry {
+	switch {
+	case snippet.BurnAfterRead:
+		return expiresBurn
+	case snippet.Permanent() && allowNever:
+		return expiresNever
+	default:
+		return "365d"
+	}
+}
+
+// language returns the language to store for the snippet, detecting it from
+// the content if the user asked for that.
+func (form *snippetCreateForm) language() string {
+	if form.Language == highlight.Auto {
+		return highlight.Detect(form.Content)
+	}
+	return form.Language
 }
 
 func (app *application) snippetCreatePost(w http.ResponseWriter, r *http.Request) {
@@ -81,30 +514,27 @@ func (app *application) snippetCreatePost(w http.ResponseWriter, r *http.Request
 		app.clientError(w, http.StatusBadRequest)
 		return
 	}
-	// Since the Validator type is embedded in the snippetCreateForm, we can
-	// call CheckField directly on the object.
-	form.CheckField(validator.NotBlank(form.Title), "title", "This field cannot be blank")
-	form.CheckField(validator.MaxChars(form.Title, 100), "title", "This field cannot be more than 100 characters long")
-	form.CheckField(validator.NotBlank(form.Content), "content", "This field cannot be blank")
-	form.CheckField(validator.PermittedValue(form.Expires, 1, 7, 365), "expires", "This field must equal 1, 7, or 365")
+	form.validate(app.allowNeverExpires)
 
 	if !form.Valid() {
 		// sending a new html form with errors if it's not valid
 		data := app.newTemplateData(r)
 		data.Form = form
-		app.render(w, http.StatusUnprocessableEntity, "create.tmpl.html", data)
+		app.render(w, r, http.StatusUnprocessableEntity, "create.tmpl.html", data)
 		return
 	}
 
-	id, err := app.snippets.Insert(form.Title, form.Content, form.Expires)
+	expires, burn := form.expires(time.Now())
+	slug, err := app.snippets.Insert(r.Context(), app.authenticatedUserID(r), form.Title, form.Content, form.Visibility, form.language(), form.Format, form.Tags, expires, burn)
 	if err != nil {
-		app.serverError(w, err)
+		app.serverError(w, r, err)
 		return
 	}
+	app.metrics.snippetsCreated.Inc()
 
 	app.sessionManager.Put(r.Context(), "flash", "Snippet successfully created!")
 
-	http.Redirect(w, r, fmt.Sprintf("/snippet/view/%d", id), http.StatusSeeOther)
+	http.Redirect(w, r, fmt.Sprintf("/snippet/view/%s", slug), http.StatusSeeOther)
 }
 
 func (app *application) snippetCreate(w http.ResponseWriter, r *http.Request) {
@@ -114,10 +544,91 @@ func (app *application) snippetCreate(w http.ResponseWriter, r *http.Request) {
 	// render. It's a good place to put default values for the fields too (e.g. Expires = 365 will default that option in the template)
 
 	data.Form = snippetCreateForm{
-		Expires: 365,
+		Visibility: models.VisibilityPublic,
+		Language:   highlight.Auto,
+		Format:     models.FormatPlain,
+		Expires:    "365d",
+	}
+
+	app.render(w, r, http.StatusOK, "create.tmpl.html", data)
+}
+
+func (app *application) snippetEdit(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.ownedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Snippet = snippet
+	data.Form = snippetCreateForm{
+		Title:      snippet.Title,
+		Content:    snippet.Content,
+		Visibility: snippet.Visibility,
+		Language:   snippet.Language,
+		Format:     snippet.Format,
+		Tags:       snippet.Tags,
+		Expires:    expiresKeep,
+	}
+
+	app.render(w, r, http.StatusOK, "edit.tmpl.html", data)
+}
+
+func (app *application) snippetEditPost(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.ownedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	var form snippetCreateForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.validateEdit(snippet, app.allowNeverExpires)
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Snippet = snippet
+		data.Form = form
+		app.render(w, r, http.StatusUnprocessableEntity, "edit.tmpl.html", data)
+		return
 	}
 
-	app.render(w, http.StatusOK, "create.tmpl.html", data)
+	expires, burn := form.editExpires(snippet, time.Now())
+	err = app.snippets.Update(r.Context(), snippet.ID, app.authenticatedUserID(r), form.Title, form.Content, form.Visibility, form.language(), form.Format, form.Tags, expires, burn)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Snippet successfully updated!")
+
+	http.Redirect(w, r, fmt.Sprintf("/snippet/view/%s", snippet.Slug), http.StatusSeeOther)
+}
+
+func (app *application) snippetDeletePost(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.ownedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.snippets.Delete(r.Context(), snippet.ID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Snippet successfully deleted!")
+
+	http.Redirect(w, r, "/account/view", http.StatusSeeOther)
 }
 
 type userSignupForm struct {
@@ -130,7 +641,7 @@ type userSignupForm struct {
 func (app *application) userSignup(w http.ResponseWriter, r *http.Request) {
 	data := app.newTemplateData(r)
 	data.Form = userSignupForm{}
-	app.render(w, http.StatusOK, "signup.tmpl.html", data)
+	app.render(w, r, http.StatusOK, "signup.tmpl.html", data)
 }
 
 func (app *application) userSignupPost(w http.ResponseWriter, r *http.Request) {
@@ -150,30 +661,121 @@ func (app *application) userSignupPost(w http.ResponseWriter, r *http.Request) {
 	if !form.Valid() {
 		data := app.newTemplateData(r)
 		data.Form = form
-		app.render(w, http.StatusUnprocessableEntity, "signup.tmpl.html", data)
+		app.render(w, r, http.StatusUnprocessableEntity, "signup.tmpl.html", data)
 		return
 	}
 
-	err = app.users.Insert(form.Name, form.Email, form.Password)
+	id, err := app.users.Insert(r.Context(), form.Name, form.Email, form.Password)
 	if err != nil {
 		if errors.Is(err, models.ErrDuplicateEmail) {
 			form.AddFieldError("email", "Email address is already in use")
 
 			data := app.newTemplateData(r)
 			data.Form = form
-			app.render(w, http.StatusUnprocessableEntity, "signup.tmpl.html", data)
+			app.render(w, r, http.StatusUnprocessableEntity, "signup.tmpl.html", data)
 		} else {
-			app.serverError(w, err)
+			app.serverError(w, r, err)
 		}
 
 		return
 	}
 
-	app.sessionManager.Put(r.Context(), "flash", "Your signup was successful. Please log in.")
+	// The account is there whether or not the email goes, so a failure
+	// isn't the user's problem; they can ask for another once logged in
+	user := &models.User{ID: id, Name: form.Name, Email: form.Email}
+	err = app.sendVerification(user)
+	if err != nil {
+		app.logger.Error("signup: sending verification email failed", "error", err, "user_id", id)
+		app.sessionManager.Put(r.Context(), "flash", "Your signup was successful, but we couldn't send the email to verify your address. Please log in and ask for another.")
+	} else {
+		app.sessionManager.Put(r.Context(), "flash", "Your signup was successful. Please log in, and follow the link we've emailed you to verify your address.")
+	}
 
 	http.Redirect(w, r, "/user/login", http.StatusSeeOther)
 }
 
+// userVerify follows the link emailed at signup. It doesn't need the user to
+// be logged in, as the link may well be opened in another browser.
+func (app *application) userVerify(w http.ResponseWriter, r *http.Request) {
+	user, err := app.checkVerifyToken(r.Context(), r.URL.Query().Get("token"))
+	if err != nil {
+		switch {
+		case errors.Is(err, errExpiredVerifyToken):
+			app.sessionManager.Put(r.Context(), "flash", "That verification link has expired. Please ask for another.")
+		case errors.Is(err, errInvalidVerifyToken):
+			app.sessionManager.Put(r.Context(), "flash", "That verification link isn't valid. Please ask for another.")
+		default:
+			app.serverError(w, r, err)
+			return
+		}
+		http.Redirect(w, r, "/user/verify/resend", http.StatusSeeOther)
+		return
+	}
+
+	if !user.Verified {
+		err = app.users.Verify(r.Context(), user.ID)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Your email address has been verified.")
+
+	if app.isAutheticated(r) {
+		http.Redirect(w, r, "/account/view", http.StatusSeeOther)
+	} else {
+		http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+	}
+}
+
+// verifyResendInterval is how long the user has to wait before asking for
+// another verification email, so the page can't be used to flood an inbox.
+const verifyResendInterval = time.Minute
+
+func (app *application) userVerifyResend(w http.ResponseWriter, r *http.Request) {
+	user, err := app.users.Get(r.Context(), app.authenticatedUserID(r))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.User = user
+	app.render(w, r, http.StatusOK, "verify.tmpl.html", data)
+}
+
+func (app *application) userVerifyResendPost(w http.ResponseWriter, r *http.Request) {
+	user, err := app.users.Get(r.Context(), app.authenticatedUserID(r))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	if user.Verified {
+		app.sessionManager.Put(r.Context(), "flash", "Your email address is already verified.")
+		http.Redirect(w, r, "/account/view", http.StatusSeeOther)
+		return
+	}
+
+	sent := time.Unix(app.sessionManager.GetInt64(r.Context(), "verifySent"), 0)
+	if time.Since(sent) < verifyResendInterval {
+		app.sessionManager.Put(r.Context(), "flash", "We've only just sent you a link. Please wait a minute before asking for another.")
+		http.Redirect(w, r, "/user/verify/resend", http.StatusSeeOther)
+		return
+	}
+
+	err = app.sendVerification(user)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	app.sessionManager.Put(r.Context(), "verifySent", time.Now().Unix())
+
+	app.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("We've sent a new link to %s.", user.Email))
+	http.Redirect(w, r, "/user/verify/resend", http.StatusSeeOther)
+}
+
 type userLoginForm struct {
 	Email               string `form:"email"`
 	Password            string `form:"password"`
@@ -183,7 +785,7 @@ type userLoginForm struct {
 func (app *application) userLogin(w http.ResponseWriter, r *http.Request) {
 	data := app.newTemplateData(r)
 	data.Form = userLoginForm{}
-	app.render(w, http.StatusOK, "login.tmpl.html", data)
+	app.render(w, r, http.StatusOK, "login.tmpl.html", data)
 }
 
 func (app *application) userLoginPost(w http.ResponseWriter, r *http.Request) {
@@ -202,27 +804,27 @@ func (app *application) userLoginPost(w http.ResponseWriter, r *http.Request) {
 	if !form.Valid() {
 		data := app.newTemplateData(r)
 		data.Form = form
-		app.render(w, http.StatusUnprocessableEntity, "login.tmpl.html", data)
+		app.render(w, r, http.StatusUnprocessableEntity, "login.tmpl.html", data)
 		return
 	}
 
-	id, err := app.users.Authenticate(form.Email, form.Password)
+	id, err := app.users.Authenticate(r.Context(), form.Email, form.Password)
 	if err != nil {
 		if errors.Is(err, models.ErrInvalidCredentials) {
 			form.AddNonFieldError("Email or password is incorrect")
 
 			data := app.newTemplateData(r)
 			data.Form = form
-			app.render(w, http.StatusUnprocessableEntity, "login.tmpl.html", data)
+			app.render(w, r, http.StatusUnprocessableEntity, "login.tmpl.html", data)
 		} else {
-			app.serverError(w, err)
+			app.serverError(w, r, err)
 		}
 		return
 	}
 
 	err = app.sessionManager.RenewToken(r.Context())
 	if err != nil {
-		app.serverError(w, err)
+		app.serverError(w, r, err)
 		return
 	}
 
@@ -240,7 +842,7 @@ func (app *application) userLogoutPost(w http.ResponseWriter, r *http.Request) {
 	// chagne session id again
 	err := app.sessionManager.RenewToken(r.Context())
 	if err != nil {
-		app.serverError(w, err)
+		app.serverError(w, r, err)
 		return
 	}
 
@@ -252,26 +854,133 @@ func (app *application) userLogoutPost(w http.ResponseWriter, r *http.Request) {
 }
 
 func (app *application) accountView(w http.ResponseWriter, r *http.Request) {
-	id := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
-	user, err := app.users.Get(id)
+	data, err := app.accountData(r)
 	if err != nil {
 		if errors.Is(err, models.ErrNoRecord) {
 			http.Redirect(w, r, "/user/login", http.StatusSeeOther)
-			return
 		} else {
-			app.serverError(w, err)
+			app.serverError(w, r, err)
 		}
+		return
+	}
+
+	data.Form = tokenCreateForm{Scopes: []string{models.ScopeRead}}
+	app.render(w, r, http.StatusOK, "account.tmpl.html", data)
+}
+
+// accountData gathers everything shown on the account page for the current
+// user.
+func (app *application) accountData(r *http.Request) (*templateData, error) {
+	id := app.authenticatedUserID(r)
+	user, err := app.users.Get(r.Context(), id)
+	if err != nil {
+		return nil, err
+	}
+
+	snippets, err := app.snippets.ByUser(r.Context(), id)
+	if err != nil {
+		return nil, err
+	}
+
+	tokens, err := app.tokens.ByUser(r.Context(), id)
+	if err != nil {
+		return nil, err
 	}
 
 	data := app.newTemplateData(r)
 	data.User = user
-	app.render(w, http.StatusOK, "account.tmpl.html", data)
+	data.Snippets = snippets
+	data.Tokens = tokens
+	return data, nil
+}
+
+type tokenCreateForm struct {
+	Name                string   `form:"name"`
+	Scopes              []string `form:"scopes"`
+	validator.Validator `form:"-"`
+}
+
+// HasScope is used by the template to tick the scopes already chosen.
+func (form tokenCreateForm) HasScope(scope string) bool {
+	for _, s := range form.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func (app *application) accountTokenCreatePost(w http.ResponseWriter, r *http.Request) {
+	var form tokenCreateForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validator.NotBlank(form.Name), "name", "This field cannot be blank")
+	form.CheckField(validator.MaxChars(form.Name, 100), "name", "This field cannot be more than 100 characters long")
+	form.CheckField(len(form.Scopes) > 0, "scopes", "Choose at least one scope")
+	for _, scope := range form.Scopes {
+		form.CheckField(validator.PermittedValue(scope, models.ScopeRead, models.ScopeWrite), "scopes", "Unknown scope")
+	}
+
+	status := http.StatusOK
+	var plaintext string
+
+	if form.Valid() {
+		plaintext, err = app.tokens.Insert(r.Context(), app.authenticatedUserID(r), form.Name, form.Scopes)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		form = tokenCreateForm{Scopes: []string{models.ScopeRead}}
+	} else {
+		status = http.StatusUnprocessableEntity
+	}
+
+	data, err := app.accountData(r)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	// The token is rendered straight away rather than redirecting, so the
+	// plaintext is never stored anywhere, not even in the session
+	data.Form = form
+	data.NewToken = plaintext
+	app.render(w, r, status, "account.tmpl.html", data)
+}
+
+func (app *application) accountTokenDeletePost(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	id, err := strconv.Atoi(params.ByName("id"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return
+	}
+
+	err = app.tokens.Delete(r.Context(), id, app.authenticatedUserID(r))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Token successfully revoked!")
+
+	http.Redirect(w, r, "/account/view", http.StatusSeeOther)
 }
 
 func (app *application) changePassword(w http.ResponseWriter, r *http.Request) {
 	data := app.newTemplateData(r)
 	data.Form = userLoginForm{}
-	app.render(w, http.StatusOK, "changePassword.tmpl.html", data)
+	app.render(w, r, http.StatusOK, "changePassword.tmpl.html", data)
 }
 
 type passwordChangeForm struct {
@@ -305,37 +1014,37 @@ func (app *application) changePasswordPost(w http.ResponseWriter, r *http.Reques
 	if !form.Valid() {
 		data := app.newTemplateData(r)
 		data.Form = form
-		app.render(w, http.StatusUnprocessableEntity, "changePassword.tmpl.html", data)
+		app.render(w, r, http.StatusUnprocessableEntity, "changePassword.tmpl.html", data)
 		return
 	}
 
 	id := app.sessionManager.Get(r.Context(), "authenticatedUserID").(int)
-	user, err := app.users.Get(id)
+	user, err := app.users.Get(r.Context(), id)
 	if err != nil {
 		// not sure what the problem would be if the session has an invalid
 		// authenticatedUserID since this route got past the Authenticate middleware
-		app.serverError(w, err)
+		app.serverError(w, r, err)
 		return
 	}
 
-	id, err = app.users.Authenticate(user.Email, form.CurrentPassword)
+	id, err = app.users.Authenticate(r.Context(), user.Email, form.CurrentPassword)
 
 	if err != nil {
 		if errors.Is(err, models.ErrInvalidCredentials) {
 			form.AddFieldError("current_pass", "Invalid current password")
 			data := app.newTemplateData(r)
 			data.Form = form
-			app.render(w, http.StatusUnprocessableEntity, "changePassword.tmpl.html", data)
+			app.render(w, r, http.StatusUnprocessableEntity, "changePassword.tmpl.html", data)
 		} else {
-			app.serverError(w, err)
+			app.serverError(w, r, err)
 		}
 		return
 	}
 
 	// The user is now authorized to make a password change
-	err = app.users.UpdatePassword(id, form.NewPassword)
+	err = app.users.UpdatePassword(r.Context(), id, form.NewPassword)
 	if err != nil {
-		app.serverError(w, err)
+		app.serverError(w, r, err)
 		return
 	}
 
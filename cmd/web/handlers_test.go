@@ -1,11 +1,17 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"net/http"
 	"net/url"
+	"strings"
 	"testing"
+	"time"
 
 	"snippetbox.cozycole.net/internal/assert"
+	"snippetbox.cozycole.net/internal/models"
+	"snippetbox.cozycole.net/internal/models/mocks"
 )
 
 func TestPing(t *testing.T) {
@@ -33,16 +39,60 @@ func TestSnippetView(t *testing.T) {
 		wantBody string
 	}{
 		{
-			name:     "Valid ID",
-			urlPath:  "/snippet/view/1",
+			name:     "Valid slug",
+			urlPath:  "/snippet/view/pond4Xk9Qa",
 			wantCode: http.StatusOK,
-			wantBody: "An old silent pond...",
+			wantBody: `<pre class="chroma"><code><span class="line"><span class="cl">An old silent pond...`,
+		},
+		{
+			name:     "Title",
+			urlPath:  "/snippet/view/pond4Xk9Qa",
+			wantCode: http.StatusOK,
+			wantBody: "<title>Snippet pond4Xk9Qa - Snippetbox</title>",
+		},
+		{
+			name:     "Markdown",
+			urlPath:  "/snippet/view/notes8RsTu",
+			wantCode: http.StatusOK,
+			wantBody: "<div class='markdown'><h1>Making tea</h1>",
+		},
+		{
+			name:     "Burn after reading",
+			urlPath:  "/snippet/view/burn3VwXyZ",
+			wantCode: http.StatusOK,
+			wantBody: "This snippet has now been deleted",
+		},
+		{
+			name:     "Tags",
+			urlPath:  "/snippet/view/pond4Xk9Qa",
+			wantCode: http.StatusOK,
+			wantBody: "<a href='/tags/haiku'>haiku</a><a href='/tags/nature'>nature</a>",
+		},
+		{
+			name:     "Non-existent slug",
+			urlPath:  "/snippet/view/zzzzzzzzzz",
+			wantCode: http.StatusNotFound,
 		},
 		{
-			name:     "Non-existent ID",
+			name:     "Private snippet of another user",
+			urlPath:  "/snippet/view/autumn2EfG",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Query timed out",
+			urlPath:  "/snippet/view/" + mocks.SlowSlug,
+			wantCode: http.StatusServiceUnavailable,
+		},
+		{
+			name:     "Non-existent legacy ID",
 			urlPath:  "/snippet/view/2",
 			wantCode: http.StatusNotFound,
 		},
+		{
+			name:     "Legacy ID of a private snippet",
+			urlPath:  "/snippet/view/4",
+			wantCode: http.StatusNotFound,
+		},
 		{
 			name:     "Decimal ID",
 			urlPath:  "/snippet/view/1.23",
@@ -71,6 +121,163 @@ func TestSnippetView(t *testing.T) {
 			}
 		})
 	}
+
+	t.Run("Markdown is sanitized", func(t *testing.T) {
+		_, _, body := ts.get(t, "/snippet/view/notes8RsTu")
+
+		if strings.Contains(body, "alert(1)") {
+			t.Errorf("got body containing %q", "alert(1)")
+		}
+	})
+
+	t.Run("Legacy ID", func(t *testing.T) {
+		code, headers, _ := ts.get(t, "/snippet/view/1/history?from=1")
+
+		assert.Equal(t, code, http.StatusMovedPermanently)
+		assert.Equal(t, headers.Get("Location"), "/snippet/view/pond4Xk9Qa/history?from=1")
+	})
+}
+
+func TestSearch(t *testing.T) {
+	app := newTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "No query",
+			urlPath:  "/search",
+			wantCode: http.StatusOK,
+			wantBody: "<form action='/search' method='GET' class='search'>",
+		},
+		{
+			name:     "Match",
+			urlPath:  "/search?q=pond",
+			wantCode: http.StatusOK,
+			wantBody: "<a href='/snippet/view/pond4Xk9Qa'>An old silent <mark>pond</mark></a>",
+		},
+		{
+			name:     "No match",
+			urlPath:  "/search?q=frog",
+			wantCode: http.StatusOK,
+			wantBody: "No snippets match your search.",
+		},
+		{
+			name:     "Invalid page",
+			urlPath:  "/search?q=pond&page=0",
+			wantCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.urlPath)
+
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestSnippetBrowse(t *testing.T) {
+	app := newTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	thisMonth := time.Now().UTC().Format("2006/01")
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "First page",
+			urlPath:  "/snippets",
+			wantCode: http.StatusOK,
+			wantBody: `<a href="/snippet/view/forest7BcD">Over the wintry forest</a>`,
+		},
+		{
+			name:     "Later page",
+			urlPath:  "/snippets?before=1641031200-1",
+			wantCode: http.StatusOK,
+			wantBody: "There are no more snippets to show.",
+		},
+		{
+			name:     "Invalid cursor",
+			urlPath:  "/snippets?before=foo",
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "Archive",
+			urlPath:  "/archive",
+			wantCode: http.StatusOK,
+			wantBody: "<a href='/archive/" + thisMonth + "'>",
+		},
+		{
+			name:     "Archive month",
+			urlPath:  "/archive/" + thisMonth,
+			wantCode: http.StatusOK,
+			wantBody: `<a href="/snippet/view/pond4Xk9Qa">An old silent pond</a>`,
+		},
+		{
+			name:     "Empty archive month",
+			urlPath:  "/archive/2001/01",
+			wantCode: http.StatusOK,
+			wantBody: "<h2>Snippets from January 2001</h2>",
+		},
+		{
+			name:     "Invalid month",
+			urlPath:  "/archive/2022/13",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Tag",
+			urlPath:  "/tags/haiku",
+			wantCode: http.StatusOK,
+			wantBody: `<a href="/snippet/view/pond4Xk9Qa">An old silent pond</a>`,
+		},
+		{
+			name:     "Unused tag",
+			urlPath:  "/tags/frog",
+			wantCode: http.StatusOK,
+			wantBody: "<h2>Snippets tagged frog</h2>",
+		},
+		{
+			name:     "Invalid tag",
+			urlPath:  "/tags/c++",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Tag cloud",
+			urlPath:  "/",
+			wantCode: http.StatusOK,
+			wantBody: "<a href='/tags/haiku' class='size-4' title='3 snippets'>haiku</a>",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.urlPath)
+
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
 }
 
 func TestSnippetCreate(t *testing.T) {
@@ -104,6 +311,159 @@ func TestSnippetCreate(t *testing.T) {
 		tag := "<form action='/snippet/create' method='POST'>"
 		assert.StringContains(t, body, tag)
 	})
+	t.Run("Unverified", func(t *testing.T) {
+		ts := newTestServer(t, app.routes())
+		defer ts.Close()
+
+		ts.loginAs(t, "carol@example.com")
+
+		statusCode, headers, _ := ts.get(t, "/snippet/create")
+		assert.Equal(t, statusCode, http.StatusSeeOther)
+		assert.Equal(t, headers.Get("Location"), "/user/verify/resend")
+
+		_, _, body := ts.get(t, "/user/verify/resend")
+		assert.StringContains(t, body, "Please verify your email address before creating snippets.")
+
+		form := url.Values{}
+		form.Add("title", "A title")
+		form.Add("content", "Some content")
+		form.Add("expires", "1")
+		form.Add("csrf_token", extractCSRFToken(t, body))
+		statusCode, headers, _ = ts.postForm(t, "/snippet/create", form)
+		assert.Equal(t, statusCode, http.StatusSeeOther)
+		assert.Equal(t, headers.Get("Location"), "/user/verify/resend")
+	})
+}
+
+func TestSnippetCreateExpiry(t *testing.T) {
+	app := newTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+
+	_, _, body := ts.get(t, "/snippet/create")
+	validCSRFToken := extractCSRFToken(t, body)
+
+	nextWeek := time.Now().UTC().Add(7 * 24 * time.Hour).Format("2006-01-02T15:04")
+	lastWeek := time.Now().UTC().Add(-7 * 24 * time.Hour).Format("2006-01-02T15:04")
+
+	tests := []struct {
+		name       string
+		visibility string
+		expires    string
+		expiresAt  string
+		allowNever bool
+		wantCode   int
+		wantBody   string
+	}{
+		{
+			name:       "Hours",
+			visibility: "public",
+			expires:    "6h",
+			wantCode:   http.StatusSeeOther,
+		},
+		{
+			name:       "Days as a number",
+			visibility: "public",
+			expires:    "7",
+			wantCode:   http.StatusSeeOther,
+		},
+		{
+			name:       "Unknown option",
+			visibility: "public",
+			expires:    "2w",
+			wantCode:   http.StatusUnprocessableEntity,
+			wantBody:   "This field must be one of the expiry options",
+		},
+		{
+			name:       "Burn after reading",
+			visibility: "unlisted",
+			expires:    "burn",
+			wantCode:   http.StatusSeeOther,
+		},
+		{
+			name:       "Public burn after reading",
+			visibility: "public",
+			expires:    "burn",
+			wantCode:   http.StatusUnprocessableEntity,
+			wantBody:   "Snippets which burn after reading cannot be public",
+		},
+		{
+			name:       "Never",
+			visibility: "public",
+			expires:    "never",
+			allowNever: true,
+			wantCode:   http.StatusSeeOther,
+		},
+		{
+			name:       "Never when disabled",
+			visibility: "public",
+			expires:    "never",
+			allowNever: false,
+			wantCode:   http.StatusUnprocessableEntity,
+			wantBody:   "Snippets must expire on this server",
+		},
+		{
+			name:       "Custom",
+			visibility: "public",
+			expires:    "custom",
+			expiresAt:  nextWeek,
+			wantCode:   http.StatusSeeOther,
+		},
+		{
+			name:       "Custom in the past",
+			visibility: "public",
+			expires:    "custom",
+			expiresAt:  lastWeek,
+			wantCode:   http.StatusUnprocessableEntity,
+			wantBody:   "This field must be in the future",
+		},
+		{
+			name:       "Custom without a time",
+			visibility: "public",
+			expires:    "custom",
+			wantCode:   http.StatusUnprocessableEntity,
+			wantBody:   "This field must be a date and time",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app.allowNeverExpires = tt.allowNever
+
+			form := url.Values{}
+			form.Add("title", "A new title")
+			form.Add("content", "An old silent pond...")
+			form.Add("visibility", tt.visibility)
+			form.Add("language", "auto")
+			form.Add("format", "plain")
+			form.Add("expires", tt.expires)
+			form.Add("expires_at", tt.expiresAt)
+			form.Add("csrf_token", validCSRFToken)
+
+			code, _, body := ts.postForm(t, "/snippet/create", form)
+
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+
+	t.Run("Never is only offered when allowed", func(t *testing.T) {
+		app.allowNeverExpires = false
+		_, _, body := ts.get(t, "/snippet/create")
+		if strings.Contains(body, "<option value='never'") {
+			t.Error("got the never option when it isn't allowed")
+		}
+
+		app.allowNeverExpires = true
+		_, _, body = ts.get(t, "/snippet/create")
+		assert.StringContains(t, body, "<option value='never'")
+	})
 }
 
 func TestUserSignup(t *testing.T) {
@@ -215,4 +575,807 @@ func TestUserSignup(t *testing.T) {
 			}
 		})
 	}
+
+	t.Run("Verification email", func(t *testing.T) {
+		msg := app.mailer.(*testMailer).last(t)
+
+		assert.Equal(t, msg.To, validEmail)
+		assert.StringContains(t, msg.Body, "Hello Bob,")
+		assert.StringContains(t, msg.Body, "https://snippetbox.example.com/user/verify?token=3.")
+	})
+
+	t.Run("Mailer failure", func(t *testing.T) {
+		app.mailer.(*testMailer).err = errors.New("connection refused")
+		defer func() { app.mailer.(*testMailer).err = nil }()
+
+		form := url.Values{}
+		form.Add("name", validName)
+		form.Add("email", validEmail)
+		form.Add("password", validPassword)
+		form.Add("csrf_token", validCSRFToken)
+		code, headers, _ := ts.postForm(t, "/user/signup", form)
+		assert.Equal(t, code, http.StatusSeeOther)
+		assert.Equal(t, headers.Get("Location"), "/user/login")
+
+		_, _, body := ts.get(t, "/user/login")
+		assert.StringContains(t, body, "we couldn&#39;t send the email to verify your address")
+	})
+}
+
+func TestUserVerify(t *testing.T) {
+	app := newTestApplication(t)
+
+	carol := &models.User{ID: 2, Email: "carol@example.com"}
+	other := newTestApplication(t)
+	other.verifyKey = []byte("fedcba9876543210fedcba9876543210")
+
+	tests := []struct {
+		name         string
+		token        string
+		wantLocation string
+		wantFlash    string
+	}{
+		{
+			name:         "Valid token",
+			token:        app.newVerifyToken(carol, time.Now().Add(time.Hour)),
+			wantLocation: "/user/login",
+			wantFlash:    "Your email address has been verified.",
+		},
+		{
+			name:         "Expired token",
+			token:        app.newVerifyToken(carol, time.Now().Add(-time.Minute)),
+			wantLocation: "/user/verify/resend",
+			wantFlash:    "That verification link has expired.",
+		},
+		{
+			name:         "Signed with another key",
+			token:        other.newVerifyToken(carol, time.Now().Add(time.Hour)),
+			wantLocation: "/user/verify/resend",
+			wantFlash:    "That verification link isn&#39;t valid.",
+		},
+		{
+			name:         "Another user's ID",
+			token:        "1" + strings.TrimPrefix(app.newVerifyToken(carol, time.Now().Add(time.Hour)), "2"),
+			wantLocation: "/user/verify/resend",
+			wantFlash:    "That verification link isn&#39;t valid.",
+		},
+		{
+			name:         "Changed email address",
+			token:        app.newVerifyToken(&models.User{ID: 2, Email: "mallory@example.com"}, time.Now().Add(time.Hour)),
+			wantLocation: "/user/verify/resend",
+			wantFlash:    "That verification link isn&#39;t valid.",
+		},
+		{
+			name:         "Non-existent user",
+			token:        app.newVerifyToken(&models.User{ID: 9, Email: "dave@example.com"}, time.Now().Add(time.Hour)),
+			wantLocation: "/user/verify/resend",
+			wantFlash:    "That verification link isn&#39;t valid.",
+		},
+		{
+			name:         "Malformed token",
+			token:        "2.soon.abc",
+			wantLocation: "/user/verify/resend",
+			wantFlash:    "That verification link isn&#39;t valid.",
+		},
+		{
+			name:         "No token",
+			wantLocation: "/user/verify/resend",
+			wantFlash:    "That verification link isn&#39;t valid.",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			code, headers, _ := ts.get(t, "/user/verify?token="+url.QueryEscape(tt.token))
+			assert.Equal(t, code, http.StatusSeeOther)
+			assert.Equal(t, headers.Get("Location"), tt.wantLocation)
+
+			// The login page shows the flash either way, as the resend page
+			// redirects there when logged out
+			_, _, body := ts.get(t, "/user/login")
+			assert.StringContains(t, body, tt.wantFlash)
+		})
+	}
+}
+
+func TestUserVerifyResend(t *testing.T) {
+	app := newTestApplication(t)
+	mailer := app.mailer.(*testMailer)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	t.Run("Unauthenticated", func(t *testing.T) {
+		code, headers, _ := ts.get(t, "/user/verify/resend")
+
+		assert.Equal(t, code, http.StatusSeeOther)
+		assert.Equal(t, headers.Get("Location"), "/user/login")
+	})
+
+	t.Run("Unverified", func(t *testing.T) {
+		ts.loginAs(t, "carol@example.com")
+
+		code, _, body := ts.get(t, "/user/verify/resend")
+		assert.Equal(t, code, http.StatusOK)
+		assert.StringContains(t, body, `<form action="/user/verify/resend" method="POST">`)
+
+		form := url.Values{}
+		form.Add("csrf_token", extractCSRFToken(t, body))
+
+		code, headers, _ := ts.postForm(t, "/user/verify/resend", form)
+		assert.Equal(t, code, http.StatusSeeOther)
+		assert.Equal(t, headers.Get("Location"), "/user/verify/resend")
+		assert.Equal(t, len(mailer.sent), 1)
+		assert.Equal(t, mailer.last(t).To, "carol@example.com")
+
+		_, _, body = ts.get(t, "/user/verify/resend")
+		assert.StringContains(t, body, "We&#39;ve sent a new link to carol@example.com.")
+
+		// Asking again straight away doesn't send another
+		ts.postForm(t, "/user/verify/resend", form)
+		assert.Equal(t, len(mailer.sent), 1)
+
+		_, _, body = ts.get(t, "/user/verify/resend")
+		assert.StringContains(t, body, "Please wait a minute")
+	})
+
+	t.Run("Verified", func(t *testing.T) {
+		ts := newTestServer(t, app.routes())
+		defer ts.Close()
+
+		ts.login(t)
+
+		_, _, body := ts.get(t, "/user/verify/resend")
+		assert.StringContains(t, body, "is verified")
+
+		form := url.Values{}
+		form.Add("csrf_token", extractCSRFToken(t, body))
+
+		code, headers, _ := ts.postForm(t, "/user/verify/resend", form)
+		assert.Equal(t, code, http.StatusSeeOther)
+		assert.Equal(t, headers.Get("Location"), "/account/view")
+		assert.Equal(t, len(mailer.sent), 1)
+	})
+}
+
+func TestAccountView(t *testing.T) {
+	app := newTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	t.Run("Unauthenticated", func(t *testing.T) {
+		code, headers, _ := ts.get(t, "/account/view")
+
+		assert.Equal(t, code, http.StatusSeeOther)
+		assert.Equal(t, headers.Get("Location"), "/user/login")
+	})
+
+	t.Run("Lists own snippets", func(t *testing.T) {
+		ts.login(t)
+
+		code, _, body := ts.get(t, "/account/view")
+
+		assert.Equal(t, code, http.StatusOK)
+		assert.StringContains(t, body, `<a href="/snippet/view/pond4Xk9Qa">An old silent pond</a>`)
+		assert.StringContains(t, body, "<td>Deploy script</td>")
+	})
+
+	t.Run("Token", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, ts.URL+"/account/view", nil)
+		assert.NilError(t, err)
+		req.Header.Set("Authorization", "Bearer "+mocks.WriteToken)
+
+		rs, err := ts.Client().Do(req)
+		assert.NilError(t, err)
+		rs.Body.Close()
+
+		assert.Equal(t, rs.StatusCode, http.StatusForbidden)
+	})
+}
+
+func TestAccountTokenCreate(t *testing.T) {
+	app := newTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+
+	_, _, body := ts.get(t, "/account/view")
+	validCSRFToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name      string
+		tokenName string
+		scopes    []string
+		wantCode  int
+		wantBody  string
+	}{
+		{
+			name:      "Valid submission",
+			tokenName: "Laptop",
+			scopes:    []string{"snippets:read", "snippets:write"},
+			wantCode:  http.StatusOK,
+			wantBody:  "<code>sbx_new</code>",
+		},
+		{
+			name:      "Empty name",
+			tokenName: "",
+			scopes:    []string{"snippets:read"},
+			wantCode:  http.StatusUnprocessableEntity,
+			wantBody:  "This field cannot be blank",
+		},
+		{
+			name:      "No scopes",
+			tokenName: "Laptop",
+			wantCode:  http.StatusUnprocessableEntity,
+			wantBody:  "Choose at least one scope",
+		},
+		{
+			name:      "Unknown scope",
+			tokenName: "Laptop",
+			scopes:    []string{"admin"},
+			wantCode:  http.StatusUnprocessableEntity,
+			wantBody:  "Unknown scope",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("name", tt.tokenName)
+			for _, scope := range tt.scopes {
+				form.Add("scopes", scope)
+			}
+			form.Add("csrf_token", validCSRFToken)
+
+			code, _, body := ts.postForm(t, "/account/tokens/create", form)
+
+			assert.Equal(t, code, tt.wantCode)
+			assert.StringContains(t, body, tt.wantBody)
+		})
+	}
+}
+
+func TestAccountTokenDelete(t *testing.T) {
+	app := newTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+
+	_, _, body := ts.get(t, "/account/view")
+	validCSRFToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+	}{
+		{
+			name:     "Own token",
+			urlPath:  "/account/tokens/delete/1",
+			wantCode: http.StatusSeeOther,
+		},
+		{
+			name:     "Non-existent token",
+			urlPath:  "/account/tokens/delete/99",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Invalid ID",
+			urlPath:  "/account/tokens/delete/foo",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("csrf_token", validCSRFToken)
+
+			code, _, _ := ts.postForm(t, tt.urlPath, form)
+
+			assert.Equal(t, code, tt.wantCode)
+		})
+	}
+}
+
+func TestSnippetEdit(t *testing.T) {
+	app := newTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	t.Run("Unauthenticated", func(t *testing.T) {
+		code, headers, _ := ts.get(t, "/snippet/edit/pond4Xk9Qa")
+
+		assert.Equal(t, code, http.StatusSeeOther)
+		assert.Equal(t, headers.Get("Location"), "/user/login")
+	})
+
+	ts.login(t)
+
+	_, _, body := ts.get(t, "/snippet/create")
+	validCSRFToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		title    string
+		language string
+		format   string
+		tags     string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "Owner",
+			urlPath:  "/snippet/edit/pond4Xk9Qa",
+			title:    "A new title",
+			language: "auto",
+			format:   "plain",
+			wantCode: http.StatusSeeOther,
+		},
+		{
+			name:     "Not owner",
+			urlPath:  "/snippet/edit/forest7BcD",
+			title:    "A new title",
+			language: "auto",
+			format:   "plain",
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "Non-existent slug",
+			urlPath:  "/snippet/edit/zzzzzzzzzz",
+			title:    "A new title",
+			language: "auto",
+			format:   "plain",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Numeric ID",
+			urlPath:  "/snippet/edit/1",
+			title:    "A new title",
+			language: "auto",
+			format:   "plain",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Empty title",
+			urlPath:  "/snippet/edit/pond4Xk9Qa",
+			title:    "",
+			language: "auto",
+			format:   "plain",
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "This field cannot be blank",
+		},
+		{
+			name:     "Unsupported language",
+			urlPath:  "/snippet/edit/pond4Xk9Qa",
+			title:    "A new title",
+			language: "cobol",
+			format:   "plain",
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "This field must be a supported language",
+		},
+		{
+			name:     "Markdown",
+			urlPath:  "/snippet/edit/pond4Xk9Qa",
+			title:    "A new title",
+			language: "auto",
+			format:   "markdown",
+			wantCode: http.StatusSeeOther,
+		},
+		{
+			name:     "Unsupported format",
+			urlPath:  "/snippet/edit/pond4Xk9Qa",
+			title:    "A new title",
+			language: "auto",
+			format:   "html",
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "This field must equal plain or markdown",
+		},
+		{
+			name:     "Tags",
+			urlPath:  "/snippet/edit/pond4Xk9Qa",
+			title:    "A new title",
+			language: "auto",
+			format:   "plain",
+			tags:     "haiku, Nature autumn,haiku",
+			wantCode: http.StatusSeeOther,
+		},
+		{
+			name:     "Too many tags",
+			urlPath:  "/snippet/edit/pond4Xk9Qa",
+			title:    "A new title",
+			language: "auto",
+			format:   "plain",
+			tags:     "a, b, c, d, e, f",
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "This field cannot have more than 5 tags",
+		},
+		{
+			name:     "Invalid tag",
+			urlPath:  "/snippet/edit/pond4Xk9Qa",
+			title:    "A new title",
+			language: "auto",
+			format:   "plain",
+			tags:     "c++",
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "Tags can only contain letters, numbers and dashes",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("title", tt.title)
+			form.Add("content", "An old silent pond...")
+			form.Add("visibility", "public")
+			form.Add("language", tt.language)
+			form.Add("format", tt.format)
+			form.Add("tags", tt.tags)
+			form.Add("expires", "7")
+			form.Add("csrf_token", validCSRFToken)
+
+			code, _, body := ts.postForm(t, tt.urlPath, form)
+
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+
+	t.Run("Form prefilled", func(t *testing.T) {
+		code, _, body := ts.get(t, "/snippet/edit/pond4Xk9Qa")
+
+		assert.Equal(t, code, http.StatusOK)
+		assert.StringContains(t, body, "<form action='/snippet/edit/pond4Xk9Qa' method='POST'>")
+		assert.StringContains(t, body, "An old silent pond...")
+		assert.StringContains(t, body, `value="haiku, nature"`)
+		assert.StringContains(t, body, "<option value='keep' selected>As it is (")
+	})
+}
+
+func TestSnippetEditExpiry(t *testing.T) {
+	app := newTestApplication(t)
+
+	snippets := &updateRecordingSnippetModel{expires: map[int]time.Time{}, burnAfterRead: map[int]bool{}}
+	app.snippets = snippets
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+
+	_, _, body := ts.get(t, "/snippet/create")
+	validCSRFToken := extractCSRFToken(t, body)
+
+	snippet, err := snippets.Peek(context.Background(), "pond4Xk9Qa")
+	assert.NilError(t, err)
+
+	tests := []struct {
+		name        string
+		expires     string
+		wantExpires time.Time
+	}{
+		{
+			name:        "Kept",
+			expires:     "keep",
+			wantExpires: snippet.Expires,
+		},
+		{
+			name:        "Left out",
+			expires:     "",
+			wantExpires: snippet.Expires,
+		},
+		{
+			name:        "Changed",
+			expires:     "1h",
+			wantExpires: time.Now().Add(time.Hour).Truncate(time.Second),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("title", "A new title")
+			form.Add("content", "An old silent pond...")
+			form.Add("visibility", "public")
+			form.Add("language", "plaintext")
+			form.Add("format", "plain")
+			form.Add("expires", tt.expires)
+			form.Add("csrf_token", validCSRFToken)
+
+			code, _, _ := ts.postForm(t, "/snippet/edit/pond4Xk9Qa", form)
+
+			assert.Equal(t, code, http.StatusSeeOther)
+			got := snippets.expires[snippet.ID]
+			if got.Sub(tt.wantExpires).Abs() > time.Second {
+				t.Errorf("got expiry: %s; want: %s", got, tt.wantExpires)
+			}
+			assert.Equal(t, snippets.burnAfterRead[snippet.ID], false)
+		})
+	}
+}
+
+func TestCurrentExpiry(t *testing.T) {
+	tests := []struct {
+		name       string
+		snippet    *models.Snippet
+		allowNever bool
+		want       expiry
+	}{
+		{
+			name:       "Expiring",
+			snippet:    &models.Snippet{Expires: time.Now().Add(24 * time.Hour)},
+			allowNever: true,
+			want:       "365d",
+		},
+		{
+			name:       "Burn after reading",
+			snippet:    &models.Snippet{Expires: time.Now().Add(24 * time.Hour), BurnAfterRead: true},
+			allowNever: false,
+			want:       expiresBurn,
+		},
+		{
+			name:       "Never",
+			snippet:    &models.Snippet{Expires: models.NoExpiry},
+			allowNever: true,
+			want:       expiresNever,
+		},
+		{
+			name:       "Never, no longer allowed",
+			snippet:    &models.Snippet{Expires: models.NoExpiry},
+			allowNever: false,
+			want:       "365d",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := currentExpiry(tt.snippet, tt.allowNever)
+
+			assert.Equal(t, got, tt.want)
+
+			// Whatever's preselected must get through validation
+			form := snippetCreateForm{
+				Title:      "A title",
+				Content:    "Some content",
+				Visibility: models.VisibilityUnlisted,
+				Language:   "plaintext",
+				Format:     models.FormatPlain,
+				Expires:    got,
+			}
+			form.validate(tt.allowNever)
+			assert.Equal(t, form.Valid(), true)
+		})
+	}
+}
+
+func TestSnippetDelete(t *testing.T) {
+	app := newTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+
+	_, _, body := ts.get(t, "/snippet/create")
+	validCSRFToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+	}{
+		{
+			name:     "Owner",
+			urlPath:  "/snippet/delete/pond4Xk9Qa",
+			wantCode: http.StatusSeeOther,
+		},
+		{
+			name:     "Not owner",
+			urlPath:  "/snippet/delete/forest7BcD",
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "Non-existent slug",
+			urlPath:  "/snippet/delete/zzzzzzzzzz",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("csrf_token", validCSRFToken)
+
+			code, _, _ := ts.postForm(t, tt.urlPath, form)
+
+			assert.Equal(t, code, tt.wantCode)
+		})
+	}
+}
+
+func TestSnippetHistory(t *testing.T) {
+	app := newTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "Latest changes",
+			urlPath:  "/snippet/view/pond4Xk9Qa/history",
+			wantCode: http.StatusOK,
+			wantBody: "<span class='insert'>&#43;An old silent pond...</span>",
+		},
+		{
+			name:     "Chosen revisions",
+			urlPath:  "/snippet/view/pond4Xk9Qa/history?from=2&to=1",
+			wantCode: http.StatusOK,
+			wantBody: "<span class='delete'>-An old silent pond...</span>",
+		},
+		{
+			name:     "Non-existent revision",
+			urlPath:  "/snippet/view/pond4Xk9Qa/history?from=7",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Private snippet of another user",
+			urlPath:  "/snippet/view/autumn2EfG/history",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Non-existent slug",
+			urlPath:  "/snippet/view/zzzzzzzzzz/history",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.urlPath)
+
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestSnippetRaw(t *testing.T) {
+	app := newTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "Valid slug",
+			urlPath:  "/snippet/raw/pond4Xk9Qa",
+			wantCode: http.StatusOK,
+			wantBody: "An old silent pond...",
+		},
+		{
+			name:     "Markdown is not rendered",
+			urlPath:  "/snippet/raw/notes8RsTu",
+			wantCode: http.StatusOK,
+			wantBody: "# Making tea\n\n<script>alert(1)</script>",
+		},
+		{
+			name:     "Private snippet of another user",
+			urlPath:  "/snippet/raw/autumn2EfG",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Non-existent slug",
+			urlPath:  "/snippet/raw/zzzzzzzzzz",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, headers, body := ts.get(t, tt.urlPath)
+
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantBody != "" {
+				assert.Equal(t, headers.Get("Content-Type"), "text/plain; charset=utf-8")
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+
+	t.Run("Not modified", func(t *testing.T) {
+		_, headers, _ := ts.get(t, "/snippet/raw/pond4Xk9Qa")
+
+		etag := headers.Get("ETag")
+		assert.StringContains(t, etag, `"`)
+		assert.Equal(t, headers.Get("Last-Modified") != "", true)
+
+		req, err := http.NewRequest(http.MethodGet, ts.URL+"/snippet/raw/pond4Xk9Qa", nil)
+		assert.NilError(t, err)
+		req.Header.Set("If-None-Match", etag)
+
+		rs, err := ts.Client().Do(req)
+		assert.NilError(t, err)
+		rs.Body.Close()
+
+		assert.Equal(t, rs.StatusCode, http.StatusNotModified)
+	})
+
+	t.Run("Burn after reading", func(t *testing.T) {
+		code, headers, body := ts.get(t, "/snippet/raw/burn3VwXyZ")
+
+		assert.Equal(t, code, http.StatusOK)
+		assert.Equal(t, body, "hunter2")
+		assert.Equal(t, headers.Get("Cache-Control"), "no-store")
+	})
+}
+
+func TestSnippetDownload(t *testing.T) {
+	app := newTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name            string
+		urlPath         string
+		wantCode        int
+		wantDisposition string
+	}{
+		{
+			name:            "Plain text",
+			urlPath:         "/snippet/download/pond4Xk9Qa",
+			wantCode:        http.StatusOK,
+			wantDisposition: "attachment; filename=an-old-silent-pond.txt",
+		},
+		{
+			name:            "Markdown",
+			urlPath:         "/snippet/download/notes8RsTu",
+			wantCode:        http.StatusOK,
+			wantDisposition: "attachment; filename=making-tea.md",
+		},
+		{
+			name:     "Private snippet of another user",
+			urlPath:  "/snippet/download/autumn2EfG",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, headers, _ := ts.get(t, tt.urlPath)
+
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantDisposition != "" {
+				assert.Equal(t, headers.Get("Content-Disposition"), tt.wantDisposition)
+			}
+		})
+	}
 }
@@ -2,29 +2,76 @@ package main
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"net/http"
+	"path/filepath"
+	"runtime"
 	"runtime/debug"
+	"strconv"
+	"strings"
 	"time"
 
+	"snippetbox.cozycole.net/internal/highlight"
+	"snippetbox.cozycole.net/internal/models"
+
 	"github.com/go-playground/form/v4"
+	"github.com/julienschmidt/httprouter"
 	"github.com/justinas/nosurf"
 )
 
-// The serverError helper writes an error message and stack trace to the errorLog,
-// then sends a generic 500 Internal Server Error response to the user.
-func (app *application) serverError(w http.ResponseWriter, err error) {
-	trace := fmt.Sprintf("%s\n%s", err.Error(), debug.Stack())
-	// 2 is inputed to look at the second frame for determining the Llongfile/Lshortfile and line number
-	// for the logged output (since we don't want to log the line number here, but wherever it is called)
-	app.errorLog.Output(2, trace)
+// The serverError helper logs an error message and stack trace, tagged with
+// the request's ID, then sends a generic 500 Internal Server Error response
+// to the user, or 503 Service Unavailable if a query timed out.
+func (app *application) serverError(w http.ResponseWriter, r *http.Request, err error) {
+	trace := app.logServerError(r, err)
+	status := serverErrorStatus(w, err)
 
 	if app.debugMode {
-		http.Error(w, trace, http.StatusInternalServerError)
+		http.Error(w, trace, status)
 	} else {
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		http.Error(w, http.StatusText(status), status)
+	}
+}
+
+// serverErrorStatus returns the status code to send for err. A query which
+// ran out of time means the database is overloaded rather than that
+// something is broken, so the client is told to try again shortly.
+func serverErrorStatus(w http.ResponseWriter, err error) int {
+	if models.IsTimeout(err) {
+		w.Header().Set("Retry-After", "5")
+		return http.StatusServiceUnavailable
 	}
+	return http.StatusInternalServerError
+}
+
+// logServerError logs err with a stack trace and the ID of the request it
+// happened in, and returns the trace to show in debug mode. The source logged
+// is wherever serverError or apiServerError was called from, since the line
+// in here wouldn't tell us anything.
+func (app *application) logServerError(r *http.Request, err error) string {
+	id := requestID(r)
+	stack := debug.Stack()
+	_, file, line, _ := runtime.Caller(2)
+
+	app.logger.Error(err.Error(),
+		"request_id", id,
+		"method", r.Method,
+		"path", r.URL.RequestURI(),
+		"source", fmt.Sprintf("%s:%d", filepath.Base(file), line),
+		"trace", string(stack),
+	)
+
+	return fmt.Sprintf("%s\nrequest_id=%s\n%s", err.Error(), id, stack)
+}
+
+// requestID returns the ID given to the request by assignRequestID, or an
+// empty string outside of that middleware.
+func requestID(r *http.Request) string {
+	id, _ := r.Context().Value(requestIDContextKey).(string)
+	return id
 }
 
 // The clientError helper sends a specific status code and corresponding description
@@ -34,6 +81,18 @@ func (app *application) clientError(w http.ResponseWriter, status int) {
 	http.Error(w, http.StatusText(status), status)
 }
 
+// invalidToken tells the client that the personal access token it sent in
+// the Authorization header isn't valid.
+func (app *application) invalidToken(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+
+	if strings.HasPrefix(r.URL.Path, "/api/") {
+		app.apiProblem(w, r, http.StatusUnauthorized, "The access token is invalid or has been revoked")
+		return
+	}
+	app.clientError(w, http.StatusUnauthorized)
+}
+
 // For consistency, we'll also implement a notFound helper. This is simply a
 // convenience wrapper around clientError which sends a 404 Not Found response to
 // the user.
@@ -43,18 +102,20 @@ func (app *application) notFound(w http.ResponseWriter) {
 
 func (app *application) newTemplateData(r *http.Request) *templateData {
 	return &templateData{
-		CurrentYear:     time.Now().Year(),
-		Flash:           app.sessionManager.PopString(r.Context(), "flash"),
-		IsAuthenticated: app.isAutheticated(r),
-		CSRFToken:       nosurf.Token(r),
+		CurrentYear:       time.Now().Year(),
+		Flash:             app.sessionManager.PopString(r.Context(), "flash"),
+		IsAuthenticated:   app.isAutheticated(r),
+		AuthenticatedID:   app.authenticatedUserID(r),
+		CSRFToken:         nosurf.Token(r),
+		AllowNeverExpires: app.allowNeverExpires,
 	}
 }
 
-func (app *application) render(w http.ResponseWriter, status int, page string, data *templateData) {
+func (app *application) render(w http.ResponseWriter, r *http.Request, status int, page string, data *templateData) {
 	ts, ok := app.templateCache[page]
 	if !ok {
 		err := fmt.Errorf("the template %s does not exist", page)
-		app.serverError(w, err)
+		app.serverError(w, r, err)
 		return
 	}
 
@@ -64,7 +125,7 @@ func (app *application) render(w http.ResponseWriter, status int, page string, d
 	// the http.ResponseWriter
 	err := ts.ExecuteTemplate(buf, "base", data)
 	if err != nil {
-		app.serverError(w, err)
+		app.serverError(w, r, err)
 		return
 	}
 
@@ -97,6 +158,251 @@ func (app *application) decodePostForm(r *http.Request, dst any) error {
 	return nil
 }
 
+// isAutheticated reports whether the authenticate middleware found a logged
+// in user or a valid personal access token.
 func (app *application) isAutheticated(r *http.Request) bool {
-	return app.sessionManager.Exists(r.Context(), "authenticatedUserID")
+	isAuthenticated, ok := r.Context().Value(isAutheticatedContextKey).(bool)
+	if !ok {
+		return false
+	}
+	return isAuthenticated
+}
+
+// authenticatedUserID returns the ID of the logged in user, or 0 if the
+// request isn't authenticated.
+func (app *application) authenticatedUserID(r *http.Request) int {
+	id, ok := r.Context().Value(authenticatedUserIDContextKey).(int)
+	if !ok {
+		return 0
+	}
+	return id
+}
+
+// hasScope reports whether the request may do what scope allows. Only
+// personal access tokens are limited to scopes, a logged in user can do
+// everything.
+func (app *application) hasScope(r *http.Request, scope string) bool {
+	token, ok := r.Context().Value(tokenContextKey).(*models.Token)
+	if !ok {
+		return true
+	}
+	return token.HasScope(scope)
+}
+
+// canView reports whether the current user is allowed to see a snippet.
+// Private snippets are only visible to their owner, and to tokens of theirs
+// with the read scope.
+func (app *application) canView(r *http.Request, snippet *models.Snippet) bool {
+	if snippet.Visibility == models.VisibilityPrivate {
+		return snippet.UserID == app.authenticatedUserID(r) && app.hasScope(r, models.ScopeRead)
+	}
+	return true
+}
+
+// requestedSnippet looks up the snippet named by the :id route parameter,
+// which holds its slug, and checks the current user may see it. If ok is
+// false the appropriate response has already been sent.
+//
+// A snippet which burns after reading is deleted by this if the current user
+// isn't its owner, so it must only be used by handlers which go on to show
+// the snippet.
+func (app *application) requestedSnippet(w http.ResponseWriter, r *http.Request) (*models.Snippet, bool) {
+	snippet, ok := app.findSnippet(w, r)
+	if !ok {
+		return nil, false
+	}
+
+	if snippet.BurnAfterRead && snippet.UserID != app.authenticatedUserID(r) {
+		var err error
+		snippet, err = app.snippets.Get(r.Context(), snippet.Slug)
+		if err != nil {
+			if errors.Is(err, models.ErrNoRecord) {
+				app.notFound(w)
+			} else {
+				app.serverError(w, r, err)
+			}
+			return nil, false
+		}
+	}
+
+	return snippet, true
+}
+
+// findSnippet is like requestedSnippet, but never deletes a snippet which
+// burns after reading.
+func (app *application) findSnippet(w http.ResponseWriter, r *http.Request) (*models.Snippet, bool) {
+	params := httprouter.ParamsFromContext(r.Context())
+	slug := params.ByName("id")
+
+	// Links from before snippets had slugs used the numeric ID
+	if id, err := strconv.Atoi(slug); err == nil && r.Method == http.MethodGet {
+		app.redirectLegacySnippet(w, r, id)
+		return nil, false
+	}
+
+	if !models.ValidSlug(slug) {
+		app.notFound(w)
+		return nil, false
+	}
+
+	snippet, err := app.snippets.Peek(r.Context(), slug)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return nil, false
+	}
+
+	if !app.canView(r, snippet) {
+		app.notFound(w)
+		return nil, false
+	}
+
+	return snippet, true
+}
+
+// redirectLegacySnippet permanently redirects a numeric snippet URL to the
+// same URL with the snippet's slug. Only public snippets are redirected, as
+// otherwise counting through IDs would reveal the slugs of unlisted ones.
+func (app *application) redirectLegacySnippet(w http.ResponseWriter, r *http.Request, id int) {
+	if id < 1 {
+		app.notFound(w)
+		return
+	}
+
+	snippet, err := app.snippets.GetByID(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	if snippet.Visibility != models.VisibilityPublic {
+		app.notFound(w)
+		return
+	}
+
+	url := *r.URL
+	url.Path = strings.Replace(r.URL.Path, "/"+strconv.Itoa(id), "/"+snippet.Slug, 1)
+	http.Redirect(w, r, url.RequestURI(), http.StatusMovedPermanently)
+}
+
+// ownedSnippet looks up the snippet named by the :id route parameter and
+// checks that it belongs to the current user. If it doesn't, the appropriate
+// error response has already been sent and ok is false.
+func (app *application) ownedSnippet(w http.ResponseWriter, r *http.Request) (*models.Snippet, bool) {
+	snippet, ok := app.findSnippet(w, r)
+	if !ok {
+		return nil, false
+	}
+
+	if snippet.UserID != app.authenticatedUserID(r) {
+		app.clientError(w, http.StatusForbidden)
+		return nil, false
+	}
+
+	return snippet, true
+}
+
+// serveSnippetContent writes a snippet's content as plain text. The ETag and
+// Last-Modified headers let http.ServeContent answer conditional requests
+// with a 304 Not Modified.
+func (app *application) serveSnippetContent(w http.ResponseWriter, r *http.Request, snippet *models.Snippet) {
+	sum := sha256.Sum256([]byte(snippet.Content))
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:])+`"`)
+	switch {
+	case snippet.BurnAfterRead:
+		// The point is that there's no copy left once it's been read
+		w.Header().Set("Cache-Control", "no-store")
+	case snippet.Visibility != models.VisibilityPublic:
+		// Keep shared caches from holding on to snippets that aren't public
+		w.Header().Set("Cache-Control", "private")
+	}
+
+	http.ServeContent(w, r, "", snippet.Updated, strings.NewReader(snippet.Content))
+}
+
+// cursorParam reads the ?before= query parameter used to page through
+// snippets. If ok is false a 400 Bad Request has already been sent.
+func (app *application) cursorParam(w http.ResponseWriter, r *http.Request) (models.Cursor, bool) {
+	before := r.URL.Query().Get("before")
+	if before == "" {
+		return models.Cursor{}, true
+	}
+
+	cursor, err := models.ParseCursor(before)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return models.Cursor{}, false
+	}
+	return cursor, true
+}
+
+// newBrowsePage trims snippets, which should hold one more than a page if
+// there is another page, down to a page and links to the next one.
+func newBrowsePage(r *http.Request, heading string, snippets []*models.Snippet) ([]*models.Snippet, *browsePage) {
+	page := &browsePage{Heading: heading}
+	if len(snippets) > browsePageSize {
+		snippets = snippets[:browsePageSize]
+		next := models.CursorAfter(snippets[len(snippets)-1])
+		page.Next = r.URL.Path + "?before=" + next.String()
+	}
+	return snippets, page
+}
+
+// tagCloudSize is the number of tags in the home page's tag cloud.
+const tagCloudSize = 30
+
+// newTagCloud sizes each tag by how often it's used compared to the most
+// used tag.
+func newTagCloud(tags []*models.TagCount) []cloudTag {
+	most := 1
+	for _, t := range tags {
+		most = max(most, t.Count)
+	}
+
+	cloud := make([]cloudTag, len(tags))
+	for i, t := range tags {
+		cloud[i] = cloudTag{Name: t.Name, Count: t.Count, Size: 1 + 3*(t.Count-1)/max(most-1, 1)}
+	}
+	return cloud
+}
+
+// downloadFilename turns a snippet's title into a filename, keeping only
+// letters and digits separated by dashes, and adds an extension for its
+// language. Markdown snippets always get ".md".
+func downloadFilename(snippet *models.Snippet) string {
+	var b strings.Builder
+	dash := false
+	for _, c := range strings.ToLower(snippet.Title) {
+		if (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(c)
+			dash = false
+		} else {
+			dash = true
+		}
+		if b.Len() >= 50 {
+			break
+		}
+	}
+
+	name := b.String()
+	if name == "" {
+		name = "snippet"
+	}
+
+	if snippet.Format == models.FormatMarkdown {
+		return name + ".md"
+	}
+	return name + highlight.Extension(snippet.Language)
 }
@@ -1,72 +1,168 @@
 package main
 
 import (
+	"context"
+	"crypto/rand"
 	"crypto/tls"
 	"database/sql"
+	"errors"
 	"flag"
+	"fmt"
 	"html/template"
-	"log"
+	"io"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
-	"time"
+	"os/signal"
+	"strings"
+	"syscall"
 
+	"snippetbox.cozycole.net/internal/mailer"
 	"snippetbox.cozycole.net/internal/models"
 
 	"github.com/alexedwards/scs/mysqlstore"
 	"github.com/alexedwards/scs/v2"
 	"github.com/go-playground/form/v4"
-	_ "github.com/go-sql-driver/mysql"
 )
 
 type application struct {
-	errorLog       *log.Logger
-	infoLog        *log.Logger
+	logger         *slog.Logger
 	snippets       models.SnippetModelInterface
 	users          models.UserModelInterface
+	tokens         models.TokenModelInterface
 	templateCache  map[string]*template.Template
 	formDecoder    *form.Decoder
 	sessionManager *scs.SessionManager
 	debugMode      bool
+	// allowNeverExpires lets users create snippets which are never deleted.
+	allowNeverExpires bool
+	metrics           *metrics
+	// metricsAddr is where /metrics is served when it isn't served with
+	// the site.
+	metricsAddr string
+	mailer      mailer.Mailer
+	// verifyKey signs the links for verifying email addresses.
+	verifyKey []byte
+	// baseURL is where the site is reached, without a trailing slash, for
+	// building links which are sent outside of a request.
+	baseURL string
 }
 
 func main() {
-	addr := flag.String("addr", ":4000", "HTTP network address")
-	dsn := flag.String("dsn", "web:pass@/snippetbox?parseTime=true", "MySQL data source name")
-	debug := flag.Bool("debug", false, "debug mode")
-
-	flag.Parse()
+	cfg, command, err := loadConfig(os.Args[1:], os.LookupEnv, os.Stderr)
+	switch {
+	case errors.Is(err, flag.ErrHelp):
+		os.Exit(0)
+	case errors.Is(err, errUsage):
+		os.Exit(2)
+	case err != nil:
+		fmt.Fprintf(os.Stderr, "invalid config:\n%s\n", err)
+		os.Exit(2)
+	}
 
-	infoLog := log.New(os.Stdout, "INFO\t", log.Ldate|log.Ltime)
-	errorLog := log.New(os.Stderr, "ERROR\t", log.Ldate|log.Ltime|log.Lshortfile)
+	logger := newLogger(os.Stdout, cfg.LogFormat)
 
-	db, err := openDB(*dsn)
+	db, dialect, err := openDB(cfg.DSN)
 	if err != nil {
-		errorLog.Fatal(err)
+		logger.Error(err.Error())
+		os.Exit(1)
 	}
 
-	defer db.Close()
+	migrator := &models.Migrator{DB: db, Dialect: dialect}
+	if action, ok := strings.CutPrefix(command, "migrate "); ok {
+		err := runMigrate(migrator, action, os.Stdout)
+		db.Close()
+		if err != nil {
+			logger.Error(err.Error())
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Migrating at startup suits a single instance. Where several start at
+	// once, run the migrate command before rolling them out instead.
+	if cfg.Migrate {
+		applied, err := migrator.Up()
+		for _, migration := range applied {
+			logger.Info("Applied migration", "version", migration.Version, "name", migration.Name)
+		}
+		if err != nil {
+			logger.Error(err.Error())
+			os.Exit(1)
+		}
+	}
 
 	templateCache, err := newTemplateCache()
 	if err != nil {
-		errorLog.Fatal(err)
+		logger.Error(err.Error())
+		os.Exit(1)
 	}
 
 	formDecoder := form.NewDecoder()
 
+	verifyKey := []byte(cfg.VerifySecret)
+	if len(verifyKey) == 0 {
+		verifyKey = make([]byte, 32)
+		_, err = rand.Read(verifyKey)
+		if err != nil {
+			logger.Error(err.Error())
+			os.Exit(1)
+		}
+		if command == "" {
+			logger.Warn("No verify-secret set, so email verification links will stop working when the server restarts")
+		}
+	}
+
+	mail, closeMail, err := newMailer(cfg, logger)
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+
+	sessionStore := newSessionStore(db, dialect)
 	sessionManager := scs.New()
-	sessionManager.Store = mysqlstore.New(db)
-	sessionManager.Lifetime = 12 * time.Hour
+	sessionManager.Store = sessionStore
+	sessionManager.Lifetime = cfg.SessionLifetime
 	sessionManager.Cookie.Secure = true
 
 	app := &application{
-		errorLog:       errorLog,
-		infoLog:        infoLog,
-		snippets:       &models.SnippetModel{DB: db},
-		users:          &models.UserModel{DB: db},
-		templateCache:  templateCache,
-		formDecoder:    formDecoder,
-		sessionManager: sessionManager,
-		debugMode:      *debug,
+		logger:            logger,
+		snippets:          &models.SnippetModel{DB: db, Dialect: dialect, Timeout: cfg.QueryTimeout},
+		users:             &models.UserModel{DB: db, Dialect: dialect, Timeout: cfg.QueryTimeout},
+		tokens:            &models.TokenModel{DB: db, Dialect: dialect, Timeout: cfg.QueryTimeout},
+		templateCache:     templateCache,
+		formDecoder:       formDecoder,
+		sessionManager:    sessionManager,
+		debugMode:         cfg.Debug,
+		allowNeverExpires: cfg.AllowNeverExpires,
+		metrics:           newMetrics(),
+		metricsAddr:       cfg.MetricsAddr,
+		mailer:            mail,
+		verifyKey:         verifyKey,
+		baseURL:           strings.TrimSuffix(cfg.BaseURL, "/"),
+	}
+	app.metrics.watchDB(db)
+	if cfg.CacheSize > 0 {
+		cache := models.NewSnippetCache(app.snippets, cfg.CacheSize, cfg.CacheTTL)
+		app.metrics.watchCache(cache)
+		app.snippets = cache
+	}
+	app.metrics.watchSessions(&models.SessionModel{DB: db, Dialect: dialect})
+
+	// A one-off purge, for running by hand or from cron when the reaper is
+	// turned off
+	if command == "purge" {
+		n, err := app.purgeExpired(context.Background())
+		sessionStore.StopCleanup()
+		closeMail()
+		db.Close()
+		if err != nil {
+			logger.Error(err.Error())
+			os.Exit(1)
+		}
+		logger.Info("Deleted expired snippets", "count", n)
+		return
 	}
 
 	tlsConfig := &tls.Config{
@@ -76,29 +172,118 @@ func main() {
 	// need to create to specify logger for
 	// print statemtns in http package functions
 	srv := &http.Server{
-		Addr:         *addr,
-		ErrorLog:     errorLog,
+		Addr:         cfg.Addr,
+		ErrorLog:     slog.NewLogLogger(logger.Handler(), slog.LevelError),
 		Handler:      app.routes(),
 		TLSConfig:    tlsConfig,
-		IdleTimeout:  time.Minute,
-		ReadTimeout:  5 * time.Second,
-		WriteTimeout: 10 * time.Second,
+		IdleTimeout:  cfg.IdleTimeout,
+		ReadTimeout:  cfg.ReadTimeout,
+		WriteTimeout: cfg.WriteTimeout,
+	}
+
+	stopMetrics := func() {}
+	if cfg.MetricsAddr != "" {
+		stopMetrics, err = app.serveMetrics(cfg.MetricsAddr)
+		if err != nil {
+			logger.Error(err.Error())
+			os.Exit(1)
+		}
 	}
 
-	infoLog.Println("Starting server on", *addr)
-	err = srv.ListenAndServeTLS("./tls/cert.pem", "./tls/key.pem")
-	errorLog.Fatal(err)
+	stopReaper := func() {}
+	if cfg.ReapInterval > 0 {
+		stopReaper = app.startReaper(cfg.ReapInterval)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	// Restore the default handling once the first signal arrives, so a
+	// second kills the process straight away if draining is taking too long
+	// for whoever sent it
+	context.AfterFunc(ctx, stop)
+
+	ln, err := net.Listen("tcp", srv.Addr)
+	if err == nil {
+		err = app.serve(ctx, srv, ln, cfg.TLSCert, cfg.TLSKey, cfg.DrainTimeout)
+	}
+
+	// Everything which uses the database has to stop before it's closed
+	stopMetrics()
+	stopReaper()
+	sessionStore.StopCleanup()
+	closeMail()
+	db.Close()
+
+	// A non-zero status tells the supervisor that requests may have been cut
+	// off, or that the server never started
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+	logger.Info("Stopped server")
 }
 
-func openDB(dsn string) (*sql.DB, error) {
-	db, err := sql.Open("mysql", dsn)
+// newLogger returns a logger which writes to w in the given format, either
+// text for people to read or JSON for the log pipeline.
+func newLogger(w io.Writer, format string) *slog.Logger {
+	if format == "json" {
+		return slog.New(slog.NewJSONHandler(w, nil))
+	}
+	return slog.New(slog.NewTextHandler(w, nil))
+}
+
+func openDB(dsn string) (*sql.DB, models.Dialect, error) {
+	db, dialect, err := models.Open(dsn)
 	if err != nil {
-		return nil, err
+		return nil, dialect, err
 	}
 	// Create a temporary connection with db.Ping to check
 	// we can access the db
 	if err = db.Ping(); err != nil {
-		return nil, err
+		return nil, dialect, err
+	}
+	return db, dialect, nil
+}
+
+// sessionStore is a session store which deletes expired sessions in the
+// background until it's told to stop.
+type sessionStore interface {
+	scs.Store
+	StopCleanup()
+}
+
+// newSessionStore returns a store which keeps sessions in the same database
+// as everything else.
+func newSessionStore(db *sql.DB, dialect models.Dialect) sessionStore {
+	if dialect == models.MySQL {
+		return mysqlstore.New(db)
 	}
-	return db, nil
+	return models.NewSessionStore(db, dialect)
+}
+
+// newMailer returns the mailer the config asks for: an SMTP server if one is
+// given, otherwise a file if one is given, otherwise the log. The function
+// it returns closes the file, if there is one, once nothing more will be
+// sent.
+func newMailer(cfg *config, logger *slog.Logger) (mailer.Mailer, func(), error) {
+	if cfg.SMTPAddr != "" {
+		m := &mailer.SMTP{
+			Addr:     cfg.SMTPAddr,
+			Username: cfg.SMTPUsername,
+			Password: cfg.SMTPPassword,
+			From:     cfg.MailFrom,
+			Timeout:  cfg.SMTPTimeout,
+		}
+		return m, func() {}, nil
+	}
+
+	if cfg.MailFile != "" {
+		f, err := os.OpenFile(cfg.MailFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+		if err != nil {
+			return nil, nil, err
+		}
+		return &mailer.Sink{W: f, From: cfg.MailFrom}, func() { f.Close() }, nil
+	}
+
+	return &mailer.Log{Logger: logger}, func() {}, nil
 }
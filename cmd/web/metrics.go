@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"time"
+
+	"snippetbox.cozycole.net/internal/models"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// metrics holds what's exposed on /metrics. They live in a registry of their
+// own rather than the global one, so that each test application starts
+// from nothing.
+type metrics struct {
+	registry        *prometheus.Registry
+	requests        *prometheus.CounterVec
+	requestDuration *prometheus.HistogramVec
+	panics          prometheus.Counter
+	snippetsCreated prometheus.Counter
+}
+
+func newMetrics() *metrics {
+	m := &metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "snippetbox_http_requests_total",
+			Help: "HTTP requests handled, by method, route and class of status code.",
+		}, []string{"method", "route", "code"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "snippetbox_http_request_duration_seconds",
+			Help:    "How long HTTP requests took to handle, by method and route.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"method", "route"}),
+		panics: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "snippetbox_http_panics_total",
+			Help: "Panics recovered while handling HTTP requests.",
+		}),
+		snippetsCreated: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "snippetbox_snippets_created_total",
+			Help: "Snippets created, through either the site or the API.",
+		}),
+	}
+
+	m.registry.MustRegister(
+		m.requests,
+		m.requestDuration,
+		m.panics,
+		m.snippetsCreated,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	return m
+}
+
+// watchDB adds the connection pool statistics of db.
+func (m *metrics) watchDB(db *sql.DB) {
+	m.registry.MustRegister(collectors.NewDBStatsCollector(db, "snippetbox"))
+}
+
+// watchSessions adds the number of active sessions, which is counted each
+// time the metrics are scraped.
+func (m *metrics) watchSessions(sessions models.SessionModelInterface) {
+	m.registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "snippetbox_sessions_active",
+		Help: "Sessions which haven't expired.",
+	}, func() float64 {
+		n, err := sessions.Active()
+		if err != nil {
+			return math.NaN()
+		}
+		return float64(n)
+	}))
+}
+
+// watchCache adds the hits and misses of the snippet cache.
+func (m *metrics) watchCache(cache *models.SnippetCache) {
+	m.registry.MustRegister(
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name: "snippetbox_cache_hits_total",
+			Help: "Snippet lookups answered from the cache.",
+		}, func() float64 {
+			hits, _ := cache.Stats()
+			return float64(hits)
+		}),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name: "snippetbox_cache_misses_total",
+			Help: "Snippet lookups which had to go to the database.",
+		}, func() float64 {
+			_, misses := cache.Stats()
+			return float64(misses)
+		}),
+	)
+}
+
+func (m *metrics) handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// observeRequest records a request which matched route, or "unmatched" if it
+// didn't match any. Methods which the routes don't use are all counted as
+// OTHER, so that clients can't make up new series.
+func (m *metrics) observeRequest(method, route string, status int, duration time.Duration) {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPatch, http.MethodDelete:
+	default:
+		method = "OTHER"
+	}
+	if route == "" {
+		route = "unmatched"
+	}
+
+	m.requests.WithLabelValues(method, route, fmt.Sprintf("%dxx", status/100)).Inc()
+	m.requestDuration.WithLabelValues(method, route).Observe(duration.Seconds())
+}
+
+// instrument counts and times each request for the metrics. They're labelled
+// by the pattern of the route the request matched, such as
+// /snippet/view/:id, rather than its path, so that there's one series per
+// route instead of one per snippet.
+func (app *application) instrument(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w}
+
+		// The route is only known once the router has run, so it's filled
+		// in by the handler which patternRouter wraps around each route
+		route := new(string)
+		ctx := context.WithValue(r.Context(), routeContextKey, route)
+
+		next.ServeHTTP(sw, r.WithContext(ctx))
+
+		app.metrics.observeRequest(r.Method, *route, sw.statusCode(), time.Since(start))
+	})
+}
+
+// patternRouter is an httprouter.Router which notes the pattern of the
+// route each request matched for instrument, as the router itself doesn't
+// say.
+type patternRouter struct {
+	*httprouter.Router
+}
+
+func (pr patternRouter) Handler(method, path string, handler http.Handler) {
+	pr.Router.Handler(method, path, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if route, ok := r.Context().Value(routeContextKey).(*string); ok {
+			*route = path
+		}
+		handler.ServeHTTP(w, r)
+	}))
+}
+
+func (pr patternRouter) HandlerFunc(method, path string, handler http.HandlerFunc) {
+	pr.Handler(method, path, handler)
+}
+
+// serveMetrics serves /metrics over plain HTTP on addr, apart from the site,
+// so it can be kept to a private network. Unlike the site it starts
+// listening straight away, so that a bad address is reported at startup.
+//
+// The returned function stops the server.
+func (app *application) serveMetrics(addr string) (func(), error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", app.metrics.handler())
+
+	srv := &http.Server{
+		Handler:      mux,
+		ErrorLog:     slog.NewLogLogger(app.logger.Handler(), slog.LevelError),
+		ReadTimeout:  5 * time.Second,
+		WriteTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		app.logger.Info("Starting metrics server", "addr", ln.Addr().String())
+		err := srv.Serve(ln)
+		if !errors.Is(err, http.ErrServerClosed) {
+			app.logger.Error(err.Error())
+		}
+	}()
+
+	return func() {
+		srv.Close()
+	}, nil
+}
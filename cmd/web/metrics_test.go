@@ -0,0 +1,57 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"snippetbox.cozycole.net/internal/assert"
+	"snippetbox.cozycole.net/internal/models"
+	"snippetbox.cozycole.net/internal/models/mocks"
+)
+
+func TestMetrics(t *testing.T) {
+	app := newTestApplication(t)
+	app.metrics.watchSessions(&mocks.SessionModel{})
+	cache := models.NewSnippetCache(app.snippets, 10, time.Minute)
+	app.metrics.watchCache(cache)
+	app.snippets = cache
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	for _, urlPath := range []string{"/snippet/view/pond4Xk9Qa", "/snippet/view/pond4Xk9Qa", "/snippet/view/forest7BcD", "/snippet/view/nothere0Xy", "/no/such/page"} {
+		ts.get(t, urlPath)
+	}
+
+	app.recoverPanic(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("oops")
+	})).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	code, _, body := ts.get(t, "/metrics")
+	assert.Equal(t, code, http.StatusOK)
+
+	// Requests are labelled by route pattern, so both snippets share a series
+	assert.StringContains(t, body, `snippetbox_http_requests_total{code="2xx",method="GET",route="/snippet/view/:id"} 3`)
+	assert.StringContains(t, body, `snippetbox_http_requests_total{code="4xx",method="GET",route="/snippet/view/:id"} 1`)
+	assert.StringContains(t, body, `snippetbox_http_requests_total{code="4xx",method="GET",route="unmatched"} 1`)
+	assert.StringContains(t, body, `snippetbox_http_request_duration_seconds_count{method="GET",route="/snippet/view/:id"} 4`)
+	assert.StringContains(t, body, "snippetbox_http_panics_total 1")
+	assert.StringContains(t, body, "snippetbox_sessions_active 2")
+
+	// The second view of the snippet came from the cache
+	assert.StringContains(t, body, "snippetbox_cache_hits_total 1")
+	assert.StringContains(t, body, "snippetbox_cache_misses_total 3")
+
+	t.Run("Separate address", func(t *testing.T) {
+		app := newTestApplication(t)
+		app.metricsAddr = "localhost:9090"
+
+		ts := newTestServer(t, app.routes())
+		defer ts.Close()
+
+		code, _, _ := ts.get(t, "/metrics")
+		assert.Equal(t, code, http.StatusNotFound)
+	})
+}
@@ -2,8 +2,16 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
+	"strings"
+	"time"
+
+	"snippetbox.cozycole.net/internal/models"
 
 	"github.com/justinas/nosurf"
 )
@@ -23,15 +31,92 @@ func secureHeaders(next http.Handler) http.Handler {
 	})
 }
 
-// method that is also middleware in order to access application attributes
-func (app *application) logRequest(next http.Handler) http.Handler {
+// assignRequestID gives each request a random ID. It's sent back in the
+// X-Request-ID header and logged with the request and any error it causes,
+// so a user's report of a problem can be matched up with the logs.
+func (app *application) assignRequestID(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		app.infoLog.Printf("%s - %s %s %s", r.RemoteAddr, r.Proto, r.Method, r.URL.RequestURI())
+		b := make([]byte, 8)
+		_, err := rand.Read(b)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		id := hex.EncodeToString(b)
+
+		w.Header().Set("X-Request-ID", id)
+		r = r.WithContext(context.WithValue(r.Context(), requestIDContextKey, id))
 
 		next.ServeHTTP(w, r)
 	})
 }
 
+// method that is also middleware in order to access application attributes.
+// One line is logged for each request once it has been handled, so that the
+// status and size of the response can be included.
+func (app *application) logRequest(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w}
+
+		next.ServeHTTP(sw, r)
+
+		ip, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			ip = r.RemoteAddr
+		}
+
+		app.logger.Info("request",
+			"request_id", requestID(r),
+			"method", r.Method,
+			"path", r.URL.RequestURI(),
+			"proto", r.Proto,
+			"status", sw.statusCode(),
+			"bytes", sw.bytes,
+			"duration", time.Since(start),
+			"remote_ip", ip,
+		)
+	})
+}
+
+// statusWriter records the status code and number of bytes of a response
+// for logRequest.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (sw *statusWriter) WriteHeader(status int) {
+	if sw.status == 0 {
+		sw.status = status
+	}
+	sw.ResponseWriter.WriteHeader(status)
+}
+
+func (sw *statusWriter) Write(b []byte) (int, error) {
+	if sw.status == 0 {
+		sw.status = http.StatusOK
+	}
+	n, err := sw.ResponseWriter.Write(b)
+	sw.bytes += n
+	return n, err
+}
+
+// Unwrap lets http.ResponseController get at the underlying writer.
+func (sw *statusWriter) Unwrap() http.ResponseWriter {
+	return sw.ResponseWriter
+}
+
+// statusCode is the status sent, which is 200 if the handler didn't write
+// anything at all.
+func (sw *statusWriter) statusCode() int {
+	if sw.status == 0 {
+		return http.StatusOK
+	}
+	return sw.status
+}
+
 func (app *application) recoverPanic(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		defer func() {
@@ -39,7 +124,8 @@ func (app *application) recoverPanic(next http.Handler) http.Handler {
 				// Acts as a triger to make Go's HTTP server automatically
 				// close the current connection
 				w.Header().Set("Connection", "close")
-				app.serverError(w, fmt.Errorf("%s", err))
+				app.metrics.panics.Inc()
+				app.serverError(w, r, fmt.Errorf("%s", err))
 			}
 		}()
 
@@ -73,16 +159,106 @@ func noSurf(next http.Handler) http.Handler {
 	return csrfHandler
 }
 
+// requireScope rejects requests authenticated with a personal access token
+// which wasn't granted scope.
+func (app *application) requireScope(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !app.hasScope(r, scope) {
+				if strings.HasPrefix(r.URL.Path, "/api/") {
+					app.apiProblem(w, r, http.StatusForbidden, fmt.Sprintf("The access token doesn't have the %s scope", scope))
+				} else {
+					app.clientError(w, http.StatusForbidden)
+				}
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// requireSession rejects requests authenticated with a personal access
+// token, for pages such as the account settings that only the user
+// themselves should use.
+func (app *application) requireSession(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.Context().Value(tokenContextKey).(*models.Token); ok {
+			app.clientError(w, http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requireVerified rejects users who haven't verified their email address
+// yet, sending people in a browser to the page to ask for another link.
+func (app *application) requireVerified(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		api := strings.HasPrefix(r.URL.Path, "/api/")
+
+		user, err := app.users.Get(r.Context(), app.authenticatedUserID(r))
+		if err != nil {
+			if api {
+				app.apiServerError(w, r, err)
+			} else {
+				app.serverError(w, r, err)
+			}
+			return
+		}
+
+		if !user.Verified {
+			if api {
+				app.apiProblem(w, r, http.StatusForbidden, "Verify your email address before creating snippets")
+			} else {
+				app.sessionManager.Put(r.Context(), "flash", "Please verify your email address before creating snippets.")
+				http.Redirect(w, r, "/user/verify/resend", http.StatusSeeOther)
+			}
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// authenticate checks who the request is from, either by a personal access
+// token in the Authorization header or else the session, and records it in
+// the request context.
 func (app *application) authenticate(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		id := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+		var id int
+		var token *models.Token
+
+		if header := r.Header.Get("Authorization"); header != "" {
+			plaintext, ok := strings.CutPrefix(header, "Bearer ")
+			if !ok {
+				app.invalidToken(w, r)
+				return
+			}
+
+			var err error
+			token, err = app.tokens.Authenticate(r.Context(), plaintext)
+			if err != nil {
+				if errors.Is(err, models.ErrInvalidCredentials) {
+					app.invalidToken(w, r)
+				} else {
+					app.serverError(w, r, err)
+				}
+				return
+			}
+			id = token.UserID
+		} else {
+			id = app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+		}
+
 		if id == 0 {
 			next.ServeHTTP(w, r)
 			return
 		}
-		exists, err := app.users.Exists(id)
+		exists, err := app.users.Exists(r.Context(), id)
 		if err != nil {
-			app.serverError(w, err)
+			app.serverError(w, r, err)
 			return
 		}
 
@@ -90,6 +266,10 @@ func (app *application) authenticate(next http.Handler) http.Handler {
 			// for some reason you can't edit the context directly, but must
 			// create a new Context object
 			ctx := context.WithValue(r.Context(), isAutheticatedContextKey, true)
+			ctx = context.WithValue(ctx, authenticatedUserIDContextKey, id)
+			if token != nil {
+				ctx = context.WithValue(ctx, tokenContextKey, token)
+			}
 			r = r.WithContext(ctx)
 		}
 
@@ -2,9 +2,12 @@ package main
 
 import (
 	"bytes"
+	"encoding/json"
 	"io"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"snippetbox.cozycole.net/internal/assert"
@@ -47,3 +50,94 @@ func TestSecureHeaders(t *testing.T) {
 	assert.Equal(t, string(body), "OK")
 
 }
+
+func TestLogRequest(t *testing.T) {
+	app := newTestApplication(t)
+
+	var logs bytes.Buffer
+	app.logger = slog.New(slog.NewJSONHandler(&logs, nil))
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/panic" {
+			panic("oops")
+		}
+		w.WriteHeader(http.StatusTeapot)
+		w.Write([]byte("short and stout"))
+	})
+	h := app.assignRequestID(app.logRequest(app.recoverPanic(next)))
+
+	tests := []struct {
+		name       string
+		urlPath    string
+		wantStatus int
+		wantBytes  int
+		wantLines  int
+	}{
+		{
+			name:       "Handled",
+			urlPath:    "/teapot?brew=1",
+			wantStatus: http.StatusTeapot,
+			wantBytes:  len("short and stout"),
+			wantLines:  1,
+		},
+		{
+			name:       "Panic",
+			urlPath:    "/panic",
+			wantStatus: http.StatusInternalServerError,
+			wantBytes:  len(http.StatusText(http.StatusInternalServerError)) + 1,
+			wantLines:  2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs.Reset()
+
+			rr := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, tt.urlPath, nil)
+			r.RemoteAddr = "192.0.2.1:54321"
+
+			h.ServeHTTP(rr, r)
+
+			id := rr.Header().Get("X-Request-ID")
+			assert.Equal(t, len(id), 16)
+
+			// Anything logged by serverError comes before the request line,
+			// and has to carry the same ID
+			lines := strings.Split(strings.TrimSpace(logs.String()), "\n")
+			assert.Equal(t, len(lines), tt.wantLines)
+			for _, line := range lines[:len(lines)-1] {
+				var entry struct {
+					Level     string `json:"level"`
+					RequestID string `json:"request_id"`
+					Trace     string `json:"trace"`
+				}
+				err := json.Unmarshal([]byte(line), &entry)
+				assert.NilError(t, err)
+				assert.Equal(t, entry.Level, "ERROR")
+				assert.Equal(t, entry.RequestID, id)
+				assert.StringContains(t, entry.Trace, "runtime/debug.Stack")
+			}
+
+			var entry struct {
+				Msg       string `json:"msg"`
+				RequestID string `json:"request_id"`
+				Method    string `json:"method"`
+				Path      string `json:"path"`
+				Status    int    `json:"status"`
+				Bytes     int    `json:"bytes"`
+				RemoteIP  string `json:"remote_ip"`
+			}
+			err := json.Unmarshal([]byte(lines[len(lines)-1]), &entry)
+			assert.NilError(t, err)
+
+			assert.Equal(t, entry.Msg, "request")
+			assert.Equal(t, entry.RequestID, id)
+			assert.Equal(t, entry.Method, http.MethodGet)
+			assert.Equal(t, entry.Path, tt.urlPath)
+			assert.Equal(t, entry.Status, tt.wantStatus)
+			assert.Equal(t, entry.Bytes, tt.wantBytes)
+			assert.Equal(t, entry.RemoteIP, "192.0.2.1")
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"snippetbox.cozycole.net/internal/models"
+)
+
+// runMigrate carries out the migrate subcommand: up applies every pending
+// migration, down reverts the latest one, and status lists them all. What
+// was done is written to w.
+func runMigrate(migrator *models.Migrator, action string, w io.Writer) error {
+	switch action {
+	case "up":
+		applied, err := migrator.Up()
+		for _, migration := range applied {
+			fmt.Fprintf(w, "applied %04d_%s\n", migration.Version, migration.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			fmt.Fprintln(w, "already up to date")
+		}
+	case "down":
+		migration, err := migrator.Down()
+		if err != nil {
+			return err
+		}
+		if migration == nil {
+			fmt.Fprintln(w, "no migrations to revert")
+			return nil
+		}
+		fmt.Fprintf(w, "reverted %04d_%s\n", migration.Version, migration.Name)
+	case "status":
+		migrations, err := migrator.Status()
+		if err != nil {
+			return err
+		}
+		for _, migration := range migrations {
+			applied := "pending"
+			if !migration.Applied.IsZero() {
+				applied = "applied " + migration.Applied.Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%04d_%-24s %s\n", migration.Version, migration.Name, applied)
+		}
+	default:
+		return fmt.Errorf("unknown migrate command %q", action)
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// reapBatchSize is the most expired snippets deleted by one statement.
+const reapBatchSize = 1000
+
+// startReaper starts deleting expired snippets in the background, once
+// straight away and then every interval. Until then expired snippets are
+// only hidden by the queries, and would stay in the database forever.
+//
+// The returned function stops the reaper and waits for it to return. Any
+// purge which is under way is cancelled, along with the batch it's deleting,
+// which as a single statement is either deleted in full or not at all.
+func (app *application) startReaper(interval time.Duration) func() {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			n, err := app.purgeExpired(ctx)
+			if err != nil {
+				app.logger.Error("reaper: purge failed", "error", err)
+			} else if n > 0 {
+				app.logger.Info("reaper: deleted expired snippets", "count", n)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return func() {
+		cancel()
+		wg.Wait()
+	}
+}
+
+// purgeExpired deletes every expired snippet, in batches of reapBatchSize,
+// and returns how many were deleted. It stops early if ctx is cancelled.
+func (app *application) purgeExpired(ctx context.Context) (int, error) {
+	total := 0
+	for ctx.Err() == nil {
+		n, err := app.snippets.DeleteExpired(ctx, reapBatchSize)
+		total += n
+		if err != nil {
+			// Being cancelled part way through a batch is just stopping
+			// early too
+			if ctx.Err() != nil {
+				break
+			}
+			return total, err
+		}
+		if n < reapBatchSize {
+			break
+		}
+	}
+	return total, nil
+}
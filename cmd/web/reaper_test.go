@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+
+	"snippetbox.cozycole.net/internal/assert"
+	"snippetbox.cozycole.net/internal/models/mocks"
+)
+
+// expiringSnippetModel pretends to hold a number of expired snippets, and
+// reports each call to DeleteExpired on deleted.
+type expiringSnippetModel struct {
+	mocks.SnippetModel
+	expired int
+	deleted chan int
+}
+
+func (m *expiringSnippetModel) DeleteExpired(ctx context.Context, limit int) (int, error) {
+	n := min(limit, m.expired)
+	m.expired -= n
+	m.deleted <- n
+	return n, nil
+}
+
+func TestPurgeExpired(t *testing.T) {
+	app := newTestApplication(t)
+
+	snippets := &expiringSnippetModel{expired: 2*reapBatchSize + 5, deleted: make(chan int, 10)}
+	app.snippets = snippets
+
+	n, err := app.purgeExpired(context.Background())
+	assert.NilError(t, err)
+	assert.Equal(t, n, 2*reapBatchSize+5)
+	assert.Equal(t, len(snippets.deleted), 3)
+
+	t.Run("Cancelled", func(t *testing.T) {
+		snippets.expired = 5
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		n, err := app.purgeExpired(ctx)
+		assert.NilError(t, err)
+		assert.Equal(t, n, 0)
+		assert.Equal(t, snippets.expired, 5)
+	})
+}
+
+func TestStartReaper(t *testing.T) {
+	app := newTestApplication(t)
+
+	var logs bytes.Buffer
+	app.logger = slog.New(slog.NewTextHandler(&logs, nil))
+
+	snippets := &expiringSnippetModel{expired: 5, deleted: make(chan int, 10)}
+	app.snippets = snippets
+
+	stop := app.startReaper(time.Hour)
+
+	// The first purge happens straight away rather than after an interval
+	select {
+	case n := <-snippets.deleted:
+		assert.Equal(t, n, 5)
+	case <-time.After(5 * time.Second):
+		t.Fatal("reaper didn't purge expired snippets")
+	}
+
+	stop()
+
+	assert.StringContains(t, logs.String(), `msg="reaper: deleted expired snippets" count=5`)
+}
@@ -2,7 +2,9 @@ package main
 
 import (
 	"net/http"
+	"strings"
 
+	"snippetbox.cozycole.net/internal/models"
 	"snippetbox.cozycole.net/ui"
 
 	"github.com/julienschmidt/httprouter"
@@ -10,10 +12,14 @@ import (
 )
 
 func (app *application) routes() http.Handler {
-	router := httprouter.New()
+	router := patternRouter{httprouter.New()}
 
 	// set custom handler when no route matches
 	router.NotFound = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/api/") {
+			app.apiNotFound(w, r)
+			return
+		}
 		app.notFound(w)
 	})
 
@@ -22,27 +28,72 @@ func (app *application) routes() http.Handler {
 
 	router.HandlerFunc(http.MethodGet, "/ping", ping)
 
+	// Unless there's a separate address for it, in which case serveMetrics
+	// handles it
+	if app.metricsAddr == "" {
+		router.Handler(http.MethodGet, "/metrics", app.metrics.handler())
+	}
+
 	dynamic := alice.New(app.sessionManager.LoadAndSave, noSurf, app.authenticate)
 
 	router.Handler(http.MethodGet, "/", dynamic.ThenFunc(app.home))
 	router.Handler(http.MethodGet, "/snippet/view/:id", dynamic.ThenFunc(app.snippetView))
+	router.Handler(http.MethodGet, "/snippet/view/:id/history", dynamic.ThenFunc(app.snippetHistory))
+	router.Handler(http.MethodGet, "/snippet/raw/:id", dynamic.ThenFunc(app.snippetRaw))
+	router.Handler(http.MethodGet, "/snippet/download/:id", dynamic.ThenFunc(app.snippetDownload))
 	router.Handler(http.MethodGet, "/user/signup", dynamic.ThenFunc(app.userSignup))
 	router.Handler(http.MethodPost, "/user/signup", dynamic.ThenFunc(app.userSignupPost))
 	router.Handler(http.MethodGet, "/user/login", dynamic.ThenFunc(app.userLogin))
 	router.Handler(http.MethodPost, "/user/login", dynamic.ThenFunc(app.userLoginPost))
+	router.Handler(http.MethodGet, "/user/verify", dynamic.ThenFunc(app.userVerify))
 	router.Handler(http.MethodGet, "/about", dynamic.ThenFunc(app.about))
+	router.Handler(http.MethodGet, "/search", dynamic.ThenFunc(app.search))
+	router.Handler(http.MethodGet, "/snippets", dynamic.ThenFunc(app.snippetBrowse))
+	router.Handler(http.MethodGet, "/archive", dynamic.ThenFunc(app.archive))
+	router.Handler(http.MethodGet, "/archive/:year/:month", dynamic.ThenFunc(app.archiveMonth))
+	router.Handler(http.MethodGet, "/tags/:tag", dynamic.ThenFunc(app.snippetsTagged))
 
 	// A protected middleware chain which includes the requireAuth middleware
 	protected := dynamic.Append(app.requireAuthentication)
 
-	router.Handler(http.MethodGet, "/snippet/create", protected.ThenFunc(app.snippetCreate))
-	router.Handler(http.MethodPost, "/snippet/create", protected.ThenFunc(app.snippetCreatePost))
+	// Personal access tokens can only change snippets if they have the write
+	// scope, and can't be used to manage the account at all
+	writer := protected.Append(app.requireScope(models.ScopeWrite))
+	account := protected.Append(app.requireSession)
+	// Only users who've verified their email address can create snippets
+	creator := writer.Append(app.requireVerified)
+
+	router.Handler(http.MethodGet, "/snippet/create", creator.ThenFunc(app.snippetCreate))
+	router.Handler(http.MethodPost, "/snippet/create", creator.ThenFunc(app.snippetCreatePost))
+	router.Handler(http.MethodGet, "/snippet/edit/:id", writer.ThenFunc(app.snippetEdit))
+	router.Handler(http.MethodPost, "/snippet/edit/:id", writer.ThenFunc(app.snippetEditPost))
+	router.Handler(http.MethodPost, "/snippet/delete/:id", writer.ThenFunc(app.snippetDeletePost))
 	router.Handler(http.MethodPost, "/user/logout", protected.ThenFunc(app.userLogoutPost))
-	router.Handler(http.MethodGet, "/account/view", protected.ThenFunc(app.accountView))
-	router.Handler(http.MethodGet, "/account/password/update", protected.ThenFunc(app.changePassword))
-	router.Handler(http.MethodPost, "/account/password/update", protected.ThenFunc(app.changePasswordPost))
+	router.Handler(http.MethodGet, "/user/verify/resend", account.ThenFunc(app.userVerifyResend))
+	router.Handler(http.MethodPost, "/user/verify/resend", account.ThenFunc(app.userVerifyResendPost))
+	router.Handler(http.MethodGet, "/account/view", account.ThenFunc(app.accountView))
+	router.Handler(http.MethodGet, "/account/password/update", account.ThenFunc(app.changePassword))
+	router.Handler(http.MethodPost, "/account/password/update", account.ThenFunc(app.changePasswordPost))
+	router.Handler(http.MethodPost, "/account/tokens/create", account.ThenFunc(app.accountTokenCreatePost))
+	router.Handler(http.MethodPost, "/account/tokens/delete/:id", account.ThenFunc(app.accountTokenDeletePost))
+
+	// The API is for scripts rather than browsers, so it skips the CSRF
+	// middleware. Instead decodeJSON only accepts JSON request bodies, which
+	// a cross-site form can't send.
+	api := alice.New(app.sessionManager.LoadAndSave, app.authenticate)
+	apiReader := api.Append(app.requireAPIAuthentication, app.requireScope(models.ScopeRead))
+	apiWriter := api.Append(app.requireAPIAuthentication, app.requireScope(models.ScopeWrite))
+
+	router.Handler(http.MethodGet, "/api/v1/snippets", api.ThenFunc(app.apiSnippetList))
+	router.Handler(http.MethodGet, "/api/v1/snippets/:id", api.ThenFunc(app.apiSnippetView))
+	router.Handler(http.MethodGet, "/api/v1/user/snippets", apiReader.ThenFunc(app.apiUserSnippets))
+	router.Handler(http.MethodPost, "/api/v1/snippets", apiWriter.Append(app.requireVerified).ThenFunc(app.apiSnippetCreate))
+	router.Handler(http.MethodPatch, "/api/v1/snippets/:id", apiWriter.ThenFunc(app.apiSnippetUpdate))
+	router.Handler(http.MethodDelete, "/api/v1/snippets/:id", apiWriter.ThenFunc(app.apiSnippetDelete))
 
-	standard := alice.New(app.recoverPanic, app.logRequest, secureHeaders)
+	// Panics are recovered inside logRequest and instrument so that they're
+	// counted as 500s, and everything needs the request ID
+	standard := alice.New(app.assignRequestID, app.logRequest, app.instrument, app.recoverPanic, secureHeaders)
 
 	// Return the 'standard' middleware chain followed by serverouter
 	return standard.Then(router)
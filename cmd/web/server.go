@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+)
+
+// serve runs srv on ln with the given TLS certificate and key until it fails
+// or ctx is done, which in production is when the process receives SIGINT or
+// SIGTERM. It then stops accepting connections and gives the requests which
+// are under way up to drainTimeout to finish, so that deploys don't cut them
+// off. An error is returned if the server failed or didn't drain in time.
+func (app *application) serve(ctx context.Context, srv *http.Server, ln net.Listener, certFile, keyFile string, drainTimeout time.Duration) error {
+	serveErr := make(chan error, 1)
+	go func() {
+		app.logger.Info("Starting server", "addr", ln.Addr().String())
+		serveErr <- srv.ServeTLS(ln, certFile, keyFile)
+	}()
+
+	select {
+	case err := <-serveErr:
+		return err
+	case <-ctx.Done():
+	}
+
+	app.logger.Info("Shutting down server, waiting for requests to finish", "drain_timeout", drainTimeout)
+
+	ctx, cancel := context.WithTimeout(context.Background(), drainTimeout)
+	defer cancel()
+
+	err := srv.Shutdown(ctx)
+	if err != nil {
+		// Cut off whatever is left rather than leave it running while the
+		// database is closed underneath it
+		srv.Close()
+		return err
+	}
+	return nil
+}
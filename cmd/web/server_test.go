@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"snippetbox.cozycole.net/internal/assert"
+)
+
+func TestServe(t *testing.T) {
+	// Borrow the test server's certificate, and a client which trusts it
+	hts := httptest.NewUnstartedServer(nil)
+	hts.StartTLS()
+	certs := hts.TLS.Certificates
+	client := hts.Client()
+	hts.Close()
+
+	tests := []struct {
+		name         string
+		requestTime  time.Duration
+		drainTimeout time.Duration
+		wantCode     int
+		wantErr      error
+	}{
+		{
+			name:         "Drained",
+			requestTime:  100 * time.Millisecond,
+			drainTimeout: 5 * time.Second,
+			wantCode:     http.StatusOK,
+		},
+		{
+			name:         "Drain timed out",
+			requestTime:  5 * time.Second,
+			drainTimeout: 100 * time.Millisecond,
+			wantErr:      context.DeadlineExceeded,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+
+			// A request which is under way when the server is told to stop,
+			// and which gives up if its connection is closed first
+			started := make(chan struct{})
+			mux := http.NewServeMux()
+			mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+				close(started)
+				select {
+				case <-time.After(tt.requestTime):
+					w.Write([]byte("OK"))
+				case <-r.Context().Done():
+				}
+			})
+
+			ln, err := net.Listen("tcp", "127.0.0.1:0")
+			assert.NilError(t, err)
+
+			srv := &http.Server{Handler: mux, TLSConfig: &tls.Config{Certificates: certs}}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			served := make(chan error, 1)
+			go func() {
+				served <- app.serve(ctx, srv, ln, "", "", tt.drainTimeout)
+			}()
+
+			type response struct {
+				code int
+				body string
+				err  error
+			}
+			responded := make(chan response, 1)
+			go func() {
+				rs, err := client.Get("https://" + ln.Addr().String() + "/slow")
+				if err != nil {
+					responded <- response{err: err}
+					return
+				}
+				defer rs.Body.Close()
+				body, err := io.ReadAll(rs.Body)
+				responded <- response{code: rs.StatusCode, body: string(body), err: err}
+			}()
+
+			<-started
+			cancel()
+
+			err = <-served
+			assert.Equal(t, err, tt.wantErr)
+
+			rs := <-responded
+			if tt.wantErr != nil {
+				// Cut off, one way or another
+				if rs.err == nil && rs.body == "OK" {
+					t.Error("got a complete response from a request which should have been cut off")
+				}
+				return
+			}
+			assert.NilError(t, rs.err)
+			assert.Equal(t, rs.code, tt.wantCode)
+			assert.Equal(t, rs.body, "OK")
+
+			// And nothing new is accepted
+			_, err = client.Get("https://" + ln.Addr().String() + "/slow")
+			if err == nil {
+				t.Error("got a response after the server shut down")
+			}
+		})
+	}
+}
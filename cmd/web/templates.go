@@ -4,8 +4,16 @@ import (
 	"html/template"
 	"io/fs"
 	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
+	"snippetbox.cozycole.net/internal/diff"
+	"snippetbox.cozycole.net/internal/highlight"
+	"snippetbox.cozycole.net/internal/markdown"
 	"snippetbox.cozycole.net/internal/models"
 	"snippetbox.cozycole.net/ui"
 )
@@ -19,11 +27,56 @@ type templateData struct {
 	CurrentYear     int
 	Snippet         *models.Snippet
 	Snippets        []*models.Snippet
+	Revisions       []*models.SnippetRevision
+	Diff            *snippetDiff
+	Tokens          []*models.Token
+	Search          *searchPage
+	Browse          *browsePage
+	Months          []*models.ArchiveMonth
+	TagCloud        []cloudTag
+	NewToken        string
 	User            *models.User
 	Form            any
 	Flash           string
 	IsAuthenticated bool
+	AuthenticatedID int
 	CSRFToken       string
+	// AllowNeverExpires is whether the snippet form offers to keep snippets
+	// forever.
+	AllowNeverExpires bool
+}
+
+// searchPage describes the page of search results being shown. PrevPage and
+// NextPage are 0 if there's no such page.
+type searchPage struct {
+	Query    string
+	Page     int
+	PrevPage int
+	NextPage int
+}
+
+// browsePage describes a page of snippets when browsing through all of them
+// or a month of the archive. Next is the URL of the following page, if there
+// is one.
+type browsePage struct {
+	Heading string
+	Next    string
+}
+
+// cloudTag is a tag shown in the home page's tag cloud. Size runs from 1 for
+// the least used tags to 4 for the most used.
+type cloudTag struct {
+	Name  string
+	Count int
+	Size  int
+}
+
+// snippetDiff holds the two revisions being compared on the history page
+// along with the unified diff of their content.
+type snippetDiff struct {
+	From  *models.SnippetRevision
+	To    *models.SnippetRevision
+	Hunks []diff.Hunk
 }
 
 func humanDate(t time.Time) string {
@@ -33,12 +86,109 @@ func humanDate(t time.Time) string {
 	return t.UTC().Format("02 Jan 2006 at 15:04")
 }
 
+// highlightCode renders snippet content as syntax highlighted HTML. If that
+// fails for any reason the content is shown unhighlighted instead.
+func highlightCode(content, language string) template.HTML {
+	h, err := highlight.HTML(content, language)
+	if err != nil {
+		return template.HTML("<pre><code>" + template.HTMLEscapeString(content) + "</code></pre>")
+	}
+	return h
+}
+
+// renderMarkdown renders markdown snippet content as sanitized HTML, falling
+// back to showing the source unrendered if that fails.
+func renderMarkdown(content string) template.HTML {
+	h, err := markdown.HTML(content)
+	if err != nil {
+		return template.HTML("<pre><code>" + template.HTMLEscapeString(content) + "</code></pre>")
+	}
+	return h
+}
+
+// searchTerms splits a search query into the words to highlight, longest
+// first so that a longer word wins over a shorter one it contains.
+func searchTerms(query string) []string {
+	terms := strings.FieldsFunc(query, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	sort.Slice(terms, func(i, j int) bool { return len(terms[i]) > len(terms[j]) })
+	return terms
+}
+
+// markMatches escapes text and wraps each occurrence of a word from query in
+// a <mark> element.
+func markMatches(text, query string) template.HTML {
+	terms := searchTerms(query)
+	if len(terms) == 0 {
+		return template.HTML(template.HTMLEscapeString(text))
+	}
+	for i, term := range terms {
+		terms[i] = regexp.QuoteMeta(term)
+	}
+	rx := regexp.MustCompile("(?i)" + strings.Join(terms, "|"))
+
+	var b strings.Builder
+	last := 0
+	for _, loc := range rx.FindAllStringIndex(text, -1) {
+		b.WriteString(template.HTMLEscapeString(text[last:loc[0]]))
+		b.WriteString("<mark>")
+		b.WriteString(template.HTMLEscapeString(text[loc[0]:loc[1]]))
+		b.WriteString("</mark>")
+		last = loc[1]
+	}
+	b.WriteString(template.HTMLEscapeString(text[last:]))
+
+	return template.HTML(b.String())
+}
+
+// excerptLength is the most characters of a snippet shown in search results.
+const excerptLength = 200
+
+// searchExcerpt returns part of content around the first word from query
+// that it contains, with the matches marked.
+func searchExcerpt(content, query string) template.HTML {
+	runes := []rune(content)
+
+	start := 0
+	lower := strings.ToLower(content)
+	for _, term := range searchTerms(query) {
+		if i := strings.Index(lower, strings.ToLower(term)); i >= 0 {
+			// Start a little before the match so that it has some context
+			start = utf8.RuneCountInString(lower[:i]) - excerptLength/4
+			break
+		}
+	}
+	if start < 0 || len(runes) <= excerptLength {
+		start = 0
+	}
+	end := start + excerptLength
+	if end > len(runes) {
+		end = len(runes)
+	}
+
+	excerpt := string(runes[start:end])
+	if start > 0 {
+		excerpt = "…" + excerpt
+	}
+	if end < len(runes) {
+		excerpt += "…"
+	}
+	return markMatches(excerpt, query)
+}
+
 // Init global variable which maps string func names to
 // functions to be used within templates (since you can call
 // functions from template). NOTE: The tempalte functions should only
 // return a single value
 var functions = template.FuncMap{
 	"humanDate": humanDate,
+	"highlight": highlightCode,
+	"markdown":  renderMarkdown,
+	"mark":      markMatches,
+	"excerpt":   searchExcerpt,
+	"join":      strings.Join,
+	"languages": func() []highlight.Language { return highlight.Languages },
 }
 
 // Getting mapping of html page filename to template set for the page
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"html/template"
+	"strings"
 	"testing"
 	"time"
 
@@ -41,3 +43,67 @@ func TestHumanDate(t *testing.T) {
 		})
 	}
 }
+
+func TestMarkMatches(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		query string
+		want  template.HTML
+	}{
+		{
+			name:  "Single word",
+			text:  "An old silent pond...",
+			query: "pond",
+			want:  "An old silent <mark>pond</mark>...",
+		},
+		{
+			name:  "Case insensitive",
+			text:  "Pond, pond",
+			query: "POND",
+			want:  "<mark>Pond</mark>, <mark>pond</mark>",
+		},
+		{
+			name:  "Several words",
+			text:  "An old silent pond",
+			query: "old pond",
+			want:  "An <mark>old</mark> silent <mark>pond</mark>",
+		},
+		{
+			name:  "Escapes text",
+			text:  "<script>pond</script>",
+			query: "pond",
+			want:  "&lt;script&gt;<mark>pond</mark>&lt;/script&gt;",
+		},
+		{
+			name:  "Ignores operators",
+			text:  "a+b (pond)",
+			query: "+pond*",
+			want:  "a+b (<mark>pond</mark>)",
+		},
+		{
+			name:  "Empty query",
+			text:  "An old silent pond",
+			query: "",
+			want:  "An old silent pond",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, markMatches(tt.text, tt.query), tt.want)
+		})
+	}
+}
+
+func TestSearchExcerpt(t *testing.T) {
+	long := strings.Repeat("a ", 200) + "pond " + strings.Repeat("b ", 200)
+
+	got := string(searchExcerpt(long, "pond"))
+
+	assert.StringContains(t, got, "<mark>pond</mark>")
+	if !strings.HasPrefix(got, "…") || !strings.HasSuffix(got, "…") {
+		t.Errorf("got %q; want an excerpt from the middle", got)
+	}
+	assert.Equal(t, string(searchExcerpt("An old silent pond", "pond")), "An old silent <mark>pond</mark>")
+}
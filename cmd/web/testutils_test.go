@@ -4,15 +4,18 @@ import (
 	"bytes"
 	"html"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"net/http/cookiejar"
 	"net/http/httptest"
 	"net/url"
 	"regexp"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"snippetbox.cozycole.net/internal/mailer"
 	"snippetbox.cozycole.net/internal/models/mocks"
 
 	"github.com/alexedwards/scs/v2"
@@ -39,16 +42,52 @@ func newTestApplication(t *testing.T) *application {
 
 	return &application{
 		// We don't want to clog up the test result output
-		errorLog:       log.New(io.Discard, "", 0),
-		infoLog:        log.New(io.Discard, "", 0),
+		logger:         slog.New(slog.NewTextHandler(io.Discard, nil)),
 		snippets:       &mocks.SnippetModel{},
 		users:          &mocks.UserModel{},
+		tokens:         &mocks.TokenModel{},
 		templateCache:  templateCache,
 		formDecoder:    formDecoder,
 		sessionManager: sessionManager,
+		// Like the -allow-never-expires flag's default
+		allowNeverExpires: true,
+		metrics:           newMetrics(),
+		mailer:            &testMailer{},
+		verifyKey:         []byte("0123456789abcdef0123456789abcdef"),
+		baseURL:           "https://snippetbox.example.com",
 	}
 }
 
+// testMailer keeps the messages sent through it for tests to look at.
+type testMailer struct {
+	mu   sync.Mutex
+	sent []mailer.Message
+	// err, if set, is returned rather than sending anything.
+	err error
+}
+
+func (m *testMailer) Send(msg mailer.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.err != nil {
+		return m.err
+	}
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+// last returns the latest message sent, failing the test if there isn't one.
+func (m *testMailer) last(t *testing.T) mailer.Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(m.sent) == 0 {
+		t.Fatal("no email sent")
+	}
+	return m.sent[len(m.sent)-1]
+}
+
 // Define a custom testServer type which embeds a httptest.Server instance.
 type testServer struct {
 	*httptest.Server
@@ -125,3 +164,54 @@ func extractCSRFToken(t *testing.T, body string) string {
 	// be automatically escaped to &#43; by the html package.
 	return html.UnescapeString(string(matches[1]))
 }
+
+// login signs in as the mock user (alice@example.com) so that subsequent
+// requests made with the test server's client are authenticated.
+func (ts *testServer) login(t *testing.T) {
+	ts.loginAs(t, "alice@example.com")
+}
+
+// loginAs signs in as the mock user with the given email address.
+func (ts *testServer) loginAs(t *testing.T, email string) {
+	_, _, body := ts.get(t, "/user/login")
+	csrfToken := extractCSRFToken(t, body)
+
+	form := url.Values{}
+	form.Add("email", email)
+	form.Add("password", "pa$$word")
+	form.Add("csrf_token", csrfToken)
+
+	code, _, _ := ts.postForm(t, "/user/login", form)
+	if code != http.StatusSeeOther {
+		t.Fatalf("login failed with status %d", code)
+	}
+}
+
+// sendJSON makes a request with body as its JSON request body. An empty body
+// sends no body or Content-Type at all, and a non-empty token is sent as a
+// bearer token.
+func (ts *testServer) sendJSON(t *testing.T, method, urlPath, token, body string) (int, http.Header, string) {
+	req, err := http.NewRequest(method, ts.URL+urlPath, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	rs, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer rs.Body.Close()
+	b, err := io.ReadAll(rs.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return rs.StatusCode, rs.Header, string(b)
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"snippetbox.cozycole.net/internal/mailer"
+	"snippetbox.cozycole.net/internal/models"
+)
+
+// verifyLifetime is how long a verification link works for.
+const verifyLifetime = 48 * time.Hour
+
+var (
+	errInvalidVerifyToken = errors.New("invalid verification token")
+	errExpiredVerifyToken = errors.New("expired verification token")
+)
+
+// A verification token is the user's ID and when the token expires, with an
+// HMAC of those and the user's email address. Nothing needs to be stored to
+// check it, and it stops working if the address is ever changed.
+//
+//	<id>.<expires, as a Unix time>.<base64url MAC>
+func (app *application) newVerifyToken(user *models.User, expires time.Time) string {
+	mac := app.verifyMAC(user.ID, expires.Unix(), user.Email)
+	return fmt.Sprintf("%d.%d.%s", user.ID, expires.Unix(), base64.RawURLEncoding.EncodeToString(mac))
+}
+
+func (app *application) verifyMAC(id int, expires int64, email string) []byte {
+	h := hmac.New(sha256.New, app.verifyKey)
+	fmt.Fprintf(h, "verify-email\n%d\n%d\n%s", id, expires, email)
+	return h.Sum(nil)
+}
+
+// checkVerifyToken returns the user the token was made for. It returns
+// errInvalidVerifyToken if the token wasn't made by this server for an
+// existing user, and errExpiredVerifyToken if it was but is too old.
+func (app *application) checkVerifyToken(ctx context.Context, token string) (*models.User, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errInvalidVerifyToken
+	}
+
+	id, err := strconv.Atoi(parts[0])
+	if err != nil || id < 1 {
+		return nil, errInvalidVerifyToken
+	}
+	expires, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return nil, errInvalidVerifyToken
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errInvalidVerifyToken
+	}
+
+	user, err := app.users.Get(ctx, id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			return nil, errInvalidVerifyToken
+		}
+		return nil, err
+	}
+
+	if !hmac.Equal(mac, app.verifyMAC(id, expires, user.Email)) {
+		return nil, errInvalidVerifyToken
+	}
+	if time.Now().Unix() >= expires {
+		return nil, errExpiredVerifyToken
+	}
+	return user, nil
+}
+
+// sendVerification emails the user a link for verifying their address.
+func (app *application) sendVerification(user *models.User) error {
+	token := app.newVerifyToken(user, time.Now().Add(verifyLifetime))
+	link := app.baseURL + "/user/verify?token=" + url.QueryEscape(token)
+
+	body := fmt.Sprintf(`Hello %s,
+
+Thanks for signing up to Snippetbox. To finish, and start creating snippets,
+follow this link to verify your email address:
+
+%s
+
+The link works for %d hours. If you didn't sign up, you can ignore this email.
+`, user.Name, link, int(verifyLifetime.Hours()))
+
+	return app.mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Body:    body,
+	})
+}
@@ -1,15 +1,35 @@
 module snippetbox.cozycole.net
 
-go 1.20
+go 1.21
 
-require github.com/go-sql-driver/mysql v1.7.1
+require (
+	github.com/alecthomas/chroma/v2 v2.14.0
+	github.com/alexedwards/scs/mysqlstore v0.0.0-20230902070821-95fa2ac9d520
+	github.com/alexedwards/scs/v2 v2.5.1
+	github.com/go-playground/form/v4 v4.2.1
+	github.com/go-sql-driver/mysql v1.7.1
+	github.com/julienschmidt/httprouter v1.3.0
+	github.com/justinas/alice v1.2.0
+	github.com/justinas/nosurf v1.1.1
+	github.com/lib/pq v1.9.0
+	github.com/mattn/go-sqlite3 v1.14.22
+	github.com/microcosm-cc/bluemonday v1.0.26
+	github.com/prometheus/client_golang v1.19.1
+	github.com/yuin/goldmark v1.7.1
+	golang.org/x/crypto v0.18.0
+	gopkg.in/yaml.v3 v3.0.1
+)
 
 require (
-	github.com/alexedwards/scs/mysqlstore v0.0.0-20230902070821-95fa2ac9d520 // indirect
-	github.com/alexedwards/scs/v2 v2.5.1 // indirect
-	github.com/go-playground/form/v4 v4.2.1 // indirect
-	github.com/julienschmidt/httprouter v1.3.0 // indirect
-	github.com/justinas/alice v1.2.0 // indirect
-	github.com/justinas/nosurf v1.1.1 // indirect
-	golang.org/x/crypto v0.13.0 // indirect
+	github.com/aymerick/douceur v0.2.0 // indirect
+	github.com/beorn7/perks v1.0.1 // indirect
+	github.com/cespare/xxhash/v2 v2.2.0 // indirect
+	github.com/dlclark/regexp2 v1.11.0 // indirect
+	github.com/gorilla/css v1.0.0 // indirect
+	github.com/prometheus/client_model v0.5.0 // indirect
+	github.com/prometheus/common v0.48.0 // indirect
+	github.com/prometheus/procfs v0.12.0 // indirect
+	golang.org/x/net v0.20.0 // indirect
+	golang.org/x/sys v0.18.0 // indirect
+	google.golang.org/protobuf v1.33.0 // indirect
 )
@@ -0,0 +1,220 @@
+// Package diff computes line based unified diffs between two texts.
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+type Op int
+
+const (
+	Equal Op = iota
+	Insert
+	Delete
+)
+
+// String returns a lowercase name for the operation, handy as a CSS class.
+func (o Op) String() string {
+	switch o {
+	case Insert:
+		return "insert"
+	case Delete:
+		return "delete"
+	default:
+		return "equal"
+	}
+}
+
+type Line struct {
+	Op   Op
+	Text string
+}
+
+// String formats the line as it appears in a unified diff, prefixed with
+// '+', '-' or a space.
+func (l Line) String() string {
+	switch l.Op {
+	case Insert:
+		return "+" + l.Text
+	case Delete:
+		return "-" + l.Text
+	default:
+		return " " + l.Text
+	}
+}
+
+// A Hunk is a group of changed lines along with the surrounding unchanged
+// context, in the same shape as an "@@ -a,b +c,d @@" block of a unified diff.
+type Hunk struct {
+	OldStart int
+	OldLines int
+	NewStart int
+	NewLines int
+	Lines    []Line
+}
+
+func (h Hunk) Header() string {
+	return fmt.Sprintf("@@ -%d,%d +%d,%d @@", h.OldStart, h.OldLines, h.NewStart, h.NewLines)
+}
+
+// maxCells caps the size of the LCS table. Past this we give up on finding
+// a minimal diff and report the whole middle section as replaced.
+const maxCells = 4_000_000
+
+// Lines compares a and b line by line and returns every line of both inputs
+// tagged with whether it was kept, inserted or deleted.
+func Lines(a, b string) []Line {
+	x, y := split(a), split(b)
+
+	// Trim the common prefix and suffix first, as edits to a snippet are
+	// usually small and this keeps the table below tiny.
+	pre := 0
+	for pre < len(x) && pre < len(y) && x[pre] == y[pre] {
+		pre++
+	}
+	suf := 0
+	for suf < len(x)-pre && suf < len(y)-pre && x[len(x)-1-suf] == y[len(y)-1-suf] {
+		suf++
+	}
+
+	var lines []Line
+	for _, l := range x[:pre] {
+		lines = append(lines, Line{Equal, l})
+	}
+	lines = append(lines, lcs(x[pre:len(x)-suf], y[pre:len(y)-suf])...)
+	for _, l := range x[len(x)-suf:] {
+		lines = append(lines, Line{Equal, l})
+	}
+	return lines
+}
+
+// Unified returns the hunks of a unified diff between a and b, with n lines
+// of context around each change. It returns nil if the texts are identical.
+func Unified(a, b string, n int) []Hunk {
+	lines := Lines(a, b)
+
+	// Count the old and new lines which come before each line so hunk
+	// headers can report where they start
+	oldBefore := make([]int, len(lines))
+	newBefore := make([]int, len(lines))
+	oldN, newN := 0, 0
+	for i, l := range lines {
+		oldBefore[i], newBefore[i] = oldN, newN
+		if l.Op != Insert {
+			oldN++
+		}
+		if l.Op != Delete {
+			newN++
+		}
+	}
+
+	var hunks []Hunk
+	start, end := -1, -1
+	flush := func() {
+		h := Hunk{Lines: lines[start : end+1]}
+		for _, l := range h.Lines {
+			if l.Op != Insert {
+				h.OldLines++
+			}
+			if l.Op != Delete {
+				h.NewLines++
+			}
+		}
+		// An empty side is reported as starting at the line before it
+		h.OldStart = oldBefore[start]
+		if h.OldLines > 0 {
+			h.OldStart++
+		}
+		h.NewStart = newBefore[start]
+		if h.NewLines > 0 {
+			h.NewStart++
+		}
+		hunks = append(hunks, h)
+	}
+
+	for i, l := range lines {
+		if l.Op == Equal {
+			continue
+		}
+		from, to := i-n, i+n
+		if from < 0 {
+			from = 0
+		}
+		if to > len(lines)-1 {
+			to = len(lines) - 1
+		}
+		if start != -1 && from > end+1 {
+			flush()
+			start = -1
+		}
+		if start == -1 {
+			start = from
+		}
+		end = to
+	}
+	if start != -1 {
+		flush()
+	}
+	return hunks
+}
+
+func split(s string) []string {
+	if s == "" {
+		return nil
+	}
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// lcs diffs x and y using the longest common subsequence of their lines.
+func lcs(x, y []string) []Line {
+	var lines []Line
+	if len(x)*len(y) > maxCells {
+		for _, l := range x {
+			lines = append(lines, Line{Delete, l})
+		}
+		for _, l := range y {
+			lines = append(lines, Line{Insert, l})
+		}
+		return lines
+	}
+
+	// table[i][j] holds the LCS length of x[i:] and y[j:]
+	w := len(y) + 1
+	table := make([]int32, (len(x)+1)*w)
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				table[i*w+j] = table[(i+1)*w+j+1] + 1
+			} else if table[(i+1)*w+j] >= table[i*w+j+1] {
+				table[i*w+j] = table[(i+1)*w+j]
+			} else {
+				table[i*w+j] = table[i*w+j+1]
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(x) && j < len(y) {
+		switch {
+		case x[i] == y[j]:
+			lines = append(lines, Line{Equal, x[i]})
+			i++
+			j++
+		case table[(i+1)*w+j] >= table[i*w+j+1]:
+			lines = append(lines, Line{Delete, x[i]})
+			i++
+		default:
+			lines = append(lines, Line{Insert, y[j]})
+			j++
+		}
+	}
+	for ; i < len(x); i++ {
+		lines = append(lines, Line{Delete, x[i]})
+	}
+	for ; j < len(y); j++ {
+		lines = append(lines, Line{Insert, y[j]})
+	}
+	return lines
+}
@@ -0,0 +1,74 @@
+package diff
+
+import (
+	"strings"
+	"testing"
+
+	"snippetbox.cozycole.net/internal/assert"
+)
+
+// render formats hunks the way `diff -u` prints them, minus the file headers.
+func render(hunks []Hunk) string {
+	var b strings.Builder
+	for _, h := range hunks {
+		b.WriteString(h.Header() + "\n")
+		for _, l := range h.Lines {
+			b.WriteString(l.String() + "\n")
+		}
+	}
+	return b.String()
+}
+
+func TestUnified(t *testing.T) {
+	tests := []struct {
+		name string
+		a    string
+		b    string
+		want string
+	}{
+		{
+			name: "Identical",
+			a:    "one\ntwo\n",
+			b:    "one\ntwo\n",
+			want: "",
+		},
+		{
+			name: "Changed line",
+			a:    "one\ntwo\nthree",
+			b:    "one\n2\nthree",
+			want: "@@ -1,3 +1,3 @@\n one\n-two\n+2\n three\n",
+		},
+		{
+			name: "From empty",
+			a:    "",
+			b:    "one\ntwo",
+			want: "@@ -0,0 +1,2 @@\n+one\n+two\n",
+		},
+		{
+			name: "Separate hunks",
+			a:    "a\nb\nc\nd\ne\nf\ng\nh\ni\nj",
+			b:    "A\nb\nc\nd\ne\nf\ng\nh\ni\nJ",
+			want: "@@ -1,2 +1,2 @@\n-a\n+A\n b\n@@ -9,2 +9,2 @@\n i\n-j\n+J\n",
+		},
+		{
+			name: "Merged hunks",
+			a:    "a\nb\nc\nd",
+			b:    "A\nb\nc\nD",
+			want: "@@ -1,4 +1,4 @@\n-a\n+A\n b\n c\n-d\n+D\n",
+		},
+		{
+			name: "CRLF line endings",
+			a:    "one\r\ntwo\r\n",
+			b:    "one\ntwo\n",
+			want: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := render(Unified(tt.a, tt.b, 1))
+
+			assert.Equal(t, got, tt.want)
+		})
+	}
+}
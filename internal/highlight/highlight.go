@@ -0,0 +1,178 @@
+// Package highlight turns snippet content into syntax highlighted HTML. The
+// HTML only uses CSS classes (see ui/static/css/highlight.css) so that it
+// works with a Content-Security-Policy that forbids inline styles.
+package highlight
+
+import (
+	"encoding/json"
+	"html/template"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+)
+
+const (
+	// Auto asks for the language to be detected from the content
+	Auto = "auto"
+	// Plain is used when no highlighting should be applied
+	Plain = "plaintext"
+)
+
+type Language struct {
+	Name      string
+	Label     string
+	Extension string
+}
+
+// Languages are the languages that can be chosen for a snippet. Name is the
+// value stored alongside the snippet and is a chroma lexer alias. Extension
+// is used to name downloaded snippets.
+var Languages = []Language{
+	{Plain, "Plain text", ".txt"},
+	{"bash", "Bash", ".sh"},
+	{"c", "C", ".c"},
+	{"cpp", "C++", ".cpp"},
+	{"css", "CSS", ".css"},
+	{"diff", "Diff", ".diff"},
+	{"docker", "Dockerfile", ".dockerfile"},
+	{"go", "Go", ".go"},
+	{"html", "HTML", ".html"},
+	{"ini", "INI", ".ini"},
+	{"java", "Java", ".java"},
+	{"javascript", "JavaScript", ".js"},
+	{"json", "JSON", ".json"},
+	{"markdown", "Markdown", ".md"},
+	{"php", "PHP", ".php"},
+	{"python", "Python", ".py"},
+	{"ruby", "Ruby", ".rb"},
+	{"rust", "Rust", ".rs"},
+	{"sql", "SQL", ".sql"},
+	{"toml", "TOML", ".toml"},
+	{"typescript", "TypeScript", ".ts"},
+	{"yaml", "YAML", ".yaml"},
+}
+
+// Names returns the Name of every supported language.
+func Names() []string {
+	names := make([]string, len(Languages))
+	for i, l := range Languages {
+		names[i] = l.Name
+	}
+	return names
+}
+
+// Extension returns the file extension for a language, or ".txt" if it
+// isn't one of Languages.
+func Extension(name string) string {
+	for _, l := range Languages {
+		if l.Name == name {
+			return l.Extension
+		}
+	}
+	return ".txt"
+}
+
+func supported(name string) bool {
+	for _, l := range Languages {
+		if l.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Each heuristic recognises a language from tell-tale content. They're tried
+// in order, so more specific patterns come first.
+var heuristics = []struct {
+	language string
+	rx       *regexp.Regexp
+}{
+	{"bash", regexp.MustCompile(`\A#!\s*\S*/(env\s+)?(ba|z|)sh\b`)},
+	{"python", regexp.MustCompile(`\A#!\s*\S*/(env\s+)?python`)},
+	{"ruby", regexp.MustCompile(`\A#!\s*\S*/(env\s+)?ruby`)},
+	{"php", regexp.MustCompile(`\A\s*<\?php`)},
+	{"html", regexp.MustCompile(`(?i)\A\s*(<!doctype html|<html)`)},
+	{"diff", regexp.MustCompile(`(?m)^(diff --git |--- \S.*\n\+\+\+ \S)`)},
+	{"go", regexp.MustCompile(`(?m)^package \w+\s*$|^func (\(\w+ \*?\w+\) )?\w+\(`)},
+	{"rust", regexp.MustCompile(`(?m)^\s*(pub )?fn \w+.*->|^\s*use \w+::`)},
+	{"docker", regexp.MustCompile(`(?m)\A(#.*\n)*FROM \S+`)},
+	{"python", regexp.MustCompile(`(?m)^(def \w+\(.*\):|class \w+(\(.*\))?:|import \w+$|from [\w.]+ import )`)},
+	{"c", regexp.MustCompile(`(?m)^#include <\w+\.h>`)},
+	{"cpp", regexp.MustCompile(`(?m)^#include <\w+>|std::`)},
+	{"java", regexp.MustCompile(`(?m)^\s*public (final )?class \w+`)},
+	{"sql", regexp.MustCompile(`(?is)\A\s*(select\s.+\sfrom\s|insert\s+into\s|update\s+\w+\s+set\s|create\s+(table|index)\s|delete\s+from\s|alter\s+table\s)`)},
+	{"javascript", regexp.MustCompile(`(?m)^\s*(const|let) \w+ = |function \w*\(.*\)\s*{|=> {|console\.log\(`)},
+	{"css", regexp.MustCompile(`(?m)^[.#]?[\w-]+(\s*[,>+~]?\s*[.#]?[\w-]+)*\s*{\s*$\n\s+[\w-]+\s*:`)},
+	{"ini", regexp.MustCompile(`(?m)\A(\s*[;#].*\n)*\s*\[[\w .-]+\]\s*$`)},
+	{"yaml", regexp.MustCompile(`(?m)\A(---\s*\n)?([\w-]+:( .*)?\n)+`)},
+}
+
+// Detect guesses the language of content, falling back to Plain when it
+// can't tell.
+func Detect(content string) string {
+	trimmed := strings.TrimSpace(content)
+	if trimmed == "" {
+		return Plain
+	}
+
+	if (strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[")) && json.Valid([]byte(trimmed)) {
+		return "json"
+	}
+
+	for _, h := range heuristics {
+		if h.rx.MatchString(content) {
+			return h.language
+		}
+	}
+
+	// Let chroma have a go, but only accept languages that can be picked in
+	// the form so editing the snippet doesn't fail validation
+	if lexer := lexers.Analyse(content); lexer != nil {
+		for _, alias := range append([]string{lexer.Config().Name}, lexer.Config().Aliases...) {
+			if name := strings.ToLower(alias); supported(name) {
+				return name
+			}
+		}
+	}
+	return Plain
+}
+
+var (
+	formatter = html.New(html.WithClasses(true), html.TabWidth(4))
+	style     = styles.Get("github")
+)
+
+// HTML renders content as highlighted HTML for the given language, wrapped
+// in a <pre class="chroma"> element.
+func HTML(content, language string) (template.HTML, error) {
+	lexer := lexers.Get(language)
+	if lexer == nil {
+		lexer = lexers.Fallback
+	}
+	lexer = chroma.Coalesce(lexer)
+
+	iterator, err := lexer.Tokenise(nil, content)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	err = formatter.Format(&b, style, iterator)
+	if err != nil {
+		return "", err
+	}
+
+	// The formatter escapes the content, so it's safe to mark as HTML
+	return template.HTML(b.String()), nil
+}
+
+// CSS writes the stylesheet matching the classes used by HTML. Its output is
+// kept in ui/static/css/highlight.css.
+func CSS(w io.Writer) error {
+	return formatter.WriteCSS(w, style)
+}
@@ -0,0 +1,86 @@
+package highlight
+
+import (
+	"strings"
+	"testing"
+
+	"snippetbox.cozycole.net/internal/assert"
+	"snippetbox.cozycole.net/ui"
+)
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{
+			name:    "Empty",
+			content: "",
+			want:    Plain,
+		},
+		{
+			name:    "Prose",
+			content: "An old silent pond...\nA frog jumps into the pond,\nsplash! Silence again.",
+			want:    Plain,
+		},
+		{
+			name:    "Shell script",
+			content: "#!/usr/bin/env bash\nset -euo pipefail\necho hi",
+			want:    "bash",
+		},
+		{
+			name:    "Go",
+			content: "package main\n\nimport \"fmt\"\n",
+			want:    "go",
+		},
+		{
+			name:    "Python",
+			content: "import os\n\ndef main():\n    print(os.getcwd())\n",
+			want:    "python",
+		},
+		{
+			name:    "JSON",
+			content: `{"addr": ":4000", "debug": false}`,
+			want:    "json",
+		},
+		{
+			name:    "SQL",
+			content: "SELECT id, title FROM snippets WHERE expires > UTC_TIMESTAMP();",
+			want:    "sql",
+		},
+		{
+			name:    "YAML",
+			content: "addr: :4000\ndebug: false\n",
+			want:    "yaml",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, Detect(tt.content), tt.want)
+		})
+	}
+}
+
+func TestHTML(t *testing.T) {
+	h, err := HTML(`fmt.Println("<script>")`, "go")
+
+	assert.NilError(t, err)
+	assert.StringContains(t, string(h), `<pre class="chroma">`)
+	assert.StringContains(t, string(h), `&lt;script&gt;`)
+	assert.Equal(t, strings.Contains(string(h), "style="), false)
+}
+
+func TestCSSUpToDate(t *testing.T) {
+	want, err := ui.Files.ReadFile("static/css/highlight.css")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got strings.Builder
+	err = CSS(&got)
+
+	assert.NilError(t, err)
+	assert.Equal(t, got.String(), string(want))
+}
@@ -0,0 +1,100 @@
+// Package mailer sends the emails the site needs, such as the link for
+// verifying an address, through whichever Mailer suits where it's running:
+// an SMTP server in production, or a file or the log in development and
+// tests, where there's nothing to send through.
+package mailer
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A Message is a plain text email to one recipient.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// A Mailer sends messages. Send returns once the message has been handed
+// on, which for SMTP means the server has accepted it, not that it has
+// been delivered.
+type Mailer interface {
+	Send(msg Message) error
+}
+
+var errHeader = errors.New("mailer: line break in header")
+
+// format returns msg as a complete email from the given sender, with CRLF
+// line endings. Addresses and subjects which contain line breaks are
+// refused, so that they can't be used to add headers.
+func (msg Message) format(from string, date time.Time) ([]byte, error) {
+	for _, value := range []string{from, msg.To, msg.Subject} {
+		if strings.ContainsAny(value, "\r\n") {
+			return nil, errHeader
+		}
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	b.WriteString("\r\n")
+
+	body := strings.ReplaceAll(msg.Body, "\r\n", "\n")
+	if !strings.HasSuffix(body, "\n") {
+		body += "\n"
+	}
+	b.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+	return b.Bytes(), nil
+}
+
+// Sink writes each message, in full, to W rather than sending it, followed
+// by a blank line. Pointed at a file, it lets the emails the site would
+// have sent be read in development.
+type Sink struct {
+	W    io.Writer
+	From string
+
+	mu sync.Mutex
+}
+
+func (s *Sink) Send(msg Message) error {
+	b, err := msg.format(s.From, time.Now())
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err = s.W.Write(append(b, "\r\n"...))
+	return err
+}
+
+// Log logs each message, body and all, rather than sending it. It's the
+// fallback when no other way of sending mail is set up, so that links in
+// the emails can still be followed in development. The bodies may hold
+// secrets such as those links, so it isn't meant for production.
+type Log struct {
+	Logger *slog.Logger
+}
+
+func (l *Log) Send(msg Message) error {
+	if strings.ContainsAny(msg.To+msg.Subject, "\r\n") {
+		return errHeader
+	}
+	l.Logger.Info("Email not sent", "to", msg.To, "subject", msg.Subject, "body", msg.Body)
+	return nil
+}
@@ -0,0 +1,128 @@
+package mailer
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"net/textproto"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"snippetbox.cozycole.net/internal/assert"
+)
+
+var testMessage = Message{
+	To:      "alice@example.com",
+	Subject: "Verify your email address",
+	Body:    "Hello Alice,\n\nFollow the link.",
+}
+
+func TestSink(t *testing.T) {
+	var buf bytes.Buffer
+	s := &Sink{W: &buf, From: "Snippetbox <no-reply@example.com>"}
+
+	assert.NilError(t, s.Send(testMessage))
+
+	out := buf.String()
+	assert.StringContains(t, out, "From: Snippetbox <no-reply@example.com>\r\n")
+	assert.StringContains(t, out, "To: alice@example.com\r\n")
+	assert.StringContains(t, out, "Subject: Verify your email address\r\n")
+	assert.StringContains(t, out, "\r\n\r\nHello Alice,\r\n\r\nFollow the link.\r\n\r\n")
+
+	t.Run("Header injection", func(t *testing.T) {
+		msg := testMessage
+		msg.Subject = "Hello\r\nBcc: mallory@example.com"
+
+		err := s.Send(msg)
+		assert.Equal(t, err, errHeader)
+	})
+}
+
+func TestSMTP(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NilError(t, err)
+	defer l.Close()
+
+	// A server which speaks just enough SMTP to take one message
+	received := make(chan string, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			received <- err.Error()
+			return
+		}
+		defer conn.Close()
+
+		tp := textproto.NewConn(conn)
+		var envelope, data strings.Builder
+		tp.PrintfLine("220 localhost ready")
+		for {
+			line, err := tp.ReadLine()
+			if err != nil {
+				received <- err.Error()
+				return
+			}
+			switch {
+			case strings.HasPrefix(line, "EHLO"):
+				tp.PrintfLine("250 localhost")
+			case strings.HasPrefix(line, "MAIL"), strings.HasPrefix(line, "RCPT"):
+				envelope.WriteString(line + "\n")
+				tp.PrintfLine("250 OK")
+			case line == "DATA":
+				tp.PrintfLine("354 Go ahead")
+				b, _ := tp.ReadDotBytes()
+				data.Write(b)
+				tp.PrintfLine("250 OK")
+			case line == "QUIT":
+				tp.PrintfLine("221 Bye")
+				received <- envelope.String() + data.String()
+				return
+			default:
+				tp.PrintfLine("502 Not implemented")
+			}
+		}
+	}()
+
+	m := &SMTP{Addr: l.Addr().String(), From: "Snippetbox <no-reply@example.com>"}
+	assert.NilError(t, m.Send(testMessage))
+
+	got := <-received
+	assert.StringContains(t, got, "MAIL FROM:<no-reply@example.com>\n")
+	assert.StringContains(t, got, "RCPT TO:<alice@example.com>\n")
+	assert.StringContains(t, got, "From: \"Snippetbox\" <no-reply@example.com>\n")
+	assert.StringContains(t, got, "To: alice@example.com\n")
+	assert.StringContains(t, got, "Subject: Verify your email address\n")
+	assert.StringContains(t, got, "Content-Type: text/plain; charset=utf-8\n")
+	assert.StringContains(t, got, "\n\nHello Alice,\n\nFollow the link.\n")
+
+	t.Run("Timeout", func(t *testing.T) {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		assert.NilError(t, err)
+		defer l.Close()
+
+		// A server which takes the connection and then says nothing
+		done := make(chan struct{})
+		defer close(done)
+		go func() {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+			<-done
+		}()
+
+		m := &SMTP{Addr: l.Addr().String(), From: "no-reply@example.com", Timeout: 100 * time.Millisecond}
+
+		start := time.Now()
+		err = m.Send(testMessage)
+		if !errors.Is(err, os.ErrDeadlineExceeded) {
+			t.Errorf("got: %v; want: %v", err, os.ErrDeadlineExceeded)
+		}
+		if time.Since(start) > 2*time.Second {
+			t.Errorf("took %s to give up", time.Since(start))
+		}
+	})
+}
@@ -0,0 +1,104 @@
+package mailer
+
+import (
+	"crypto/tls"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"time"
+)
+
+// SMTP sends messages through an SMTP server, such as a relay on port 587.
+// The connection is upgraded with STARTTLS when the server offers it, and
+// must have been before a username and password are sent. Servers which
+// only speak TLS from the start, on port 465, aren't supported.
+type SMTP struct {
+	// Addr is the server's host and port.
+	Addr     string
+	Username string
+	Password string
+	// From is the sender, either a bare address or one with a name such as
+	// "Snippetbox <no-reply@example.com>".
+	From string
+	// Timeout limits how long sending each message may take. Zero means
+	// ten seconds.
+	Timeout time.Duration
+}
+
+func (m *SMTP) Send(msg Message) error {
+	from, err := mail.ParseAddress(m.From)
+	if err != nil {
+		return err
+	}
+	to, err := mail.ParseAddress(msg.To)
+	if err != nil {
+		return err
+	}
+
+	b, err := msg.format(from.String(), time.Now())
+	if err != nil {
+		return err
+	}
+
+	timeout := m.Timeout
+	if timeout == 0 {
+		timeout = 10 * time.Second
+	}
+
+	host, _, err := net.SplitHostPort(m.Addr)
+	if err != nil {
+		return err
+	}
+
+	// smtp.SendMail would do, except that it can hang for as long as the
+	// server likes, and the user is waiting
+	conn, err := net.DialTimeout("tcp", m.Addr, timeout)
+	if err != nil {
+		return err
+	}
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		err = c.StartTLS(&tls.Config{ServerName: host})
+		if err != nil {
+			return err
+		}
+	}
+	if m.Username != "" {
+		err = c.Auth(smtp.PlainAuth("", m.Username, m.Password, host))
+		if err != nil {
+			return err
+		}
+	}
+
+	err = c.Mail(from.Address)
+	if err != nil {
+		return err
+	}
+	err = c.Rcpt(to.Address)
+	if err != nil {
+		return err
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	if err != nil {
+		return err
+	}
+	err = w.Close()
+	if err != nil {
+		return err
+	}
+
+	return c.Quit()
+}
@@ -0,0 +1,100 @@
+// Package markdown renders markdown snippets as HTML. Fenced code blocks
+// are syntax highlighted like plain snippets, and the result is passed
+// through a strict allowlist sanitizer so nothing a user writes can run
+// script or restyle the page.
+package markdown
+
+import (
+	"bytes"
+	"html/template"
+	"regexp"
+	"strings"
+
+	"snippetbox.cozycole.net/internal/highlight"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/util"
+)
+
+// Raw HTML in the source is dropped by goldmark since the html.WithUnsafe
+// option isn't used. The sanitizer is there in case anything gets past that.
+var md = goldmark.New(
+	goldmark.WithExtensions(
+		extension.Table,
+		extension.Strikethrough,
+		extension.Linkify,
+	),
+	goldmark.WithRendererOptions(
+		// Lower values take precedence over goldmark's own renderers
+		renderer.WithNodeRenderers(util.Prioritized(codeBlockRenderer{}, 100)),
+	),
+)
+
+// policy allows the usual user generated content elements, plus the classes
+// the highlighter puts on code. Style attributes, scripts and event handlers
+// aren't in the allowlist so they are always removed.
+var policy = newPolicy()
+
+func newPolicy() *bluemonday.Policy {
+	p := bluemonday.UGCPolicy()
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^[a-zA-Z0-9 -]+$`)).OnElements("pre", "code", "span")
+	return p
+}
+
+// HTML renders markdown content as sanitized HTML.
+func HTML(content string) (template.HTML, error) {
+	var buf bytes.Buffer
+	err := md.Convert([]byte(content), &buf)
+	if err != nil {
+		return "", err
+	}
+
+	// The sanitizer only lets through allowlisted markup, so it's safe to
+	// mark as HTML
+	return template.HTML(policy.SanitizeBytes(buf.Bytes())), nil
+}
+
+// codeBlockRenderer renders fenced code blocks with the highlight package
+// rather than as a bare <pre><code> block.
+type codeBlockRenderer struct{}
+
+func (r codeBlockRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(ast.KindFencedCodeBlock, r.renderFencedCodeBlock)
+}
+
+func (r codeBlockRenderer) renderFencedCodeBlock(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+
+	n := node.(*ast.FencedCodeBlock)
+
+	language := highlight.Plain
+	if lang := n.Language(source); lang != nil {
+		language = strings.ToLower(string(lang))
+	}
+
+	var code strings.Builder
+	lines := n.Lines()
+	for i := 0; i < lines.Len(); i++ {
+		line := lines.At(i)
+		code.Write(line.Value(source))
+	}
+
+	h, err := highlight.HTML(code.String(), language)
+	if err != nil {
+		return ast.WalkStop, err
+	}
+	_, err = w.WriteString(string(h))
+	if err != nil {
+		return ast.WalkStop, err
+	}
+
+	// The lines have been written out already, so there's no need to visit
+	// the block's children
+	return ast.WalkSkipChildren, nil
+}
@@ -0,0 +1,81 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+
+	"snippetbox.cozycole.net/internal/assert"
+)
+
+func TestHTML(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+		notWant []string
+	}{
+		{
+			name:    "Heading and emphasis",
+			content: "# Making tea\n\nBoil the *kettle* first.",
+			want:    []string{"<h1>Making tea</h1>", "<p>Boil the <em>kettle</em> first.</p>"},
+		},
+		{
+			name:    "Fenced code block",
+			content: "```go\nfmt.Println(\"hi\")\n```",
+			want:    []string{`<pre class="chroma"><code>`, `<span class="nx">fmt</span>`},
+			notWant: []string{"style="},
+		},
+		{
+			name:    "Fenced code block without a language",
+			content: "```\nls -l\n```",
+			want:    []string{`<pre class="chroma"><code><span class="line"><span class="cl">ls -l`},
+		},
+		{
+			name:    "Fenced code block escapes its content",
+			content: "```\n<script>alert(1)</script>\n```",
+			want:    []string{"&lt;script&gt;alert(1)&lt;/script&gt;"},
+			notWant: []string{"<script>"},
+		},
+		{
+			name:    "Raw script",
+			content: "Hello <script>alert(1)</script>",
+			notWant: []string{"<script"},
+		},
+		{
+			name:    "Inline event handler",
+			content: `<img src="x.png" onerror="alert(1)">`,
+			notWant: []string{"onerror"},
+		},
+		{
+			name:    "Style attribute",
+			content: `<p style="position:fixed">Hello</p>`,
+			notWant: []string{"style="},
+		},
+		{
+			name:    "Javascript link",
+			content: "[click](javascript:alert(1))",
+			notWant: []string{"javascript:"},
+		},
+		{
+			name:    "Link",
+			content: "[Go](https://go.dev)",
+			want:    []string{`<a href="https://go.dev" rel="nofollow">Go</a>`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, err := HTML(tt.content)
+			assert.NilError(t, err)
+
+			for _, want := range tt.want {
+				assert.StringContains(t, string(h), want)
+			}
+			for _, notWant := range tt.notWant {
+				if strings.Contains(string(h), notWant) {
+					t.Errorf("got %q; expected it not to contain %q", h, notWant)
+				}
+			}
+		})
+	}
+}
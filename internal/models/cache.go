@@ -0,0 +1,305 @@
+package models
+
+import (
+	"container/list"
+	"context"
+	"slices"
+	"sync"
+	"time"
+)
+
+// SnippetCache is a SnippetModelInterface which keeps the snippets looked up
+// most often, and the latest snippets and tag cloud for the home page, in
+// memory in front of another one. The least recently used entry is dropped once there are
+// more than the cache's size, and each lasts for its TTL at most, or until
+// the snippet expires if that's sooner.
+//
+// Changes made through the cache drop the entries they affect. Changes made
+// elsewhere, such as by another instance of the server, are only seen once
+// the entries have lived out their TTL.
+type SnippetCache struct {
+	SnippetModelInterface
+
+	size int
+	ttl  time.Duration
+	now  func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	// lru holds the *cacheEntry values, most recently used at the front.
+	lru *list.List
+	// slugs maps the ID of each cached snippet to its slug, as updates and
+	// deletes only give the ID.
+	slugs  map[int]string
+	hits   uint64
+	misses uint64
+}
+
+type cacheEntry struct {
+	key     string
+	expires time.Time
+	// Only one of these is set. None is ever modified, so they're copied
+	// on the way in and out.
+	snippet  *Snippet
+	snippets []*Snippet
+	tags     []*TagCount
+	// limit is the limit the tags were looked up with.
+	limit int
+}
+
+// latestKey and tagCloudKey are the keys the results of Latest and TagCloud
+// are cached under. Snippets are cached under their slug, which never
+// contains a colon.
+const (
+	latestKey   = "latest:"
+	tagCloudKey = "tags:"
+)
+
+// NewSnippetCache returns a cache of up to size entries, each kept for at most
+// ttl, in front of next.
+func NewSnippetCache(next SnippetModelInterface, size int, ttl time.Duration) *SnippetCache {
+	return &SnippetCache{
+		SnippetModelInterface: next,
+		size:                  size,
+		ttl:                   ttl,
+		now:                   time.Now,
+		entries:               map[string]*list.Element{},
+		lru:                   list.New(),
+		slugs:                 map[int]string{},
+	}
+}
+
+// Stats returns how many lookups were answered from the cache, and how many
+// had to go to the database.
+func (c *SnippetCache) Stats() (hits, misses uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.hits, c.misses
+}
+
+func (c *SnippetCache) Insert(ctx context.Context, userID int, title string, content string, visibility string, language string, format string, tags []string, expires time.Time, burnAfterRead bool) (string, error) {
+	slug, err := c.SnippetModelInterface.Insert(ctx, userID, title, content, visibility, language, format, tags, expires, burnAfterRead)
+	if err == nil {
+		c.remove(latestKey)
+		c.remove(tagCloudKey)
+	}
+	return slug, err
+}
+
+// Get returns the snippet from the cache if it's there. A snippet which
+// burns after reading is never cached, so that getting it always deletes it.
+func (c *SnippetCache) Get(ctx context.Context, slug string) (*Snippet, error) {
+	if s, ok := c.getSnippet(slug); ok {
+		return s, nil
+	}
+
+	s, err := c.SnippetModelInterface.Get(ctx, slug)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.BurnAfterRead {
+		// It's gone now, so mustn't be listed any longer
+		c.remove(latestKey)
+		return s, nil
+	}
+	c.putSnippet(s)
+	return copySnippet(s), nil
+}
+
+func (c *SnippetCache) Peek(ctx context.Context, slug string) (*Snippet, error) {
+	if s, ok := c.getSnippet(slug); ok {
+		return s, nil
+	}
+
+	s, err := c.SnippetModelInterface.Peek(ctx, slug)
+	if err != nil {
+		return nil, err
+	}
+
+	if !s.BurnAfterRead {
+		c.putSnippet(s)
+	}
+	return copySnippet(s), nil
+}
+
+func (c *SnippetCache) Latest(ctx context.Context) ([]*Snippet, error) {
+	c.mu.Lock()
+	e, ok := c.get(latestKey)
+	c.mu.Unlock()
+	if ok {
+		return copySnippets(e.snippets), nil
+	}
+
+	snippets, err := c.SnippetModelInterface.Latest(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	expires := c.now().Add(c.ttl)
+	for _, s := range snippets {
+		if s.Expires.Before(expires) {
+			expires = s.Expires
+		}
+	}
+
+	c.mu.Lock()
+	c.put(&cacheEntry{key: latestKey, expires: expires, snippets: copySnippets(snippets)})
+	c.mu.Unlock()
+
+	return snippets, nil
+}
+
+// TagCloud returns the tag cloud from the cache if it's there and was looked
+// up with the same limit. Only one limit is cached, as the home page always
+// asks for the same one.
+func (c *SnippetCache) TagCloud(ctx context.Context, limit int) ([]*TagCount, error) {
+	c.mu.Lock()
+	e, ok := c.get(tagCloudKey)
+	c.mu.Unlock()
+	if ok && e.limit == limit {
+		return copyTagCounts(e.tags), nil
+	}
+
+	tags, err := c.SnippetModelInterface.TagCloud(ctx, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.put(&cacheEntry{key: tagCloudKey, expires: c.now().Add(c.ttl), tags: copyTagCounts(tags), limit: limit})
+	c.mu.Unlock()
+
+	return tags, nil
+}
+
+func (c *SnippetCache) Update(ctx context.Context, id int, userID int, title string, content string, visibility string, language string, format string, tags []string, expires time.Time, burnAfterRead bool) error {
+	err := c.SnippetModelInterface.Update(ctx, id, userID, title, content, visibility, language, format, tags, expires, burnAfterRead)
+	c.forget(id)
+	return err
+}
+
+func (c *SnippetCache) Delete(ctx context.Context, id int) error {
+	err := c.SnippetModelInterface.Delete(ctx, id)
+	c.forget(id)
+	return err
+}
+
+// forget drops the snippet with the given ID, and the latest snippets and
+// tag cloud as it might be counted in them. It's done whether or not the
+// change succeeded, as it may have been made even if the error came after.
+func (c *SnippetCache) forget(id int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if slug, ok := c.slugs[id]; ok {
+		c.removeElement(c.entries[slug])
+	}
+	for _, key := range []string{latestKey, tagCloudKey} {
+		if el, ok := c.entries[key]; ok {
+			c.removeElement(el)
+		}
+	}
+}
+
+func (c *SnippetCache) getSnippet(slug string) (*Snippet, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.get(slug)
+	if !ok {
+		return nil, false
+	}
+	return copySnippet(e.snippet), true
+}
+
+func (c *SnippetCache) putSnippet(s *Snippet) {
+	expires := c.now().Add(c.ttl)
+	if s.Expires.Before(expires) {
+		expires = s.Expires
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.put(&cacheEntry{key: s.Slug, expires: expires, snippet: copySnippet(s)})
+	c.slugs[s.ID] = s.Slug
+}
+
+// get looks key up, counting the hit or miss. The caller must hold c.mu.
+func (c *SnippetCache) get(key string) (*cacheEntry, bool) {
+	el, ok := c.entries[key]
+	if !ok {
+		c.misses++
+		return nil, false
+	}
+
+	e := el.Value.(*cacheEntry)
+	if !c.now().Before(e.expires) {
+		c.removeElement(el)
+		c.misses++
+		return nil, false
+	}
+
+	c.lru.MoveToFront(el)
+	c.hits++
+	return e, true
+}
+
+// put adds e, replacing any entry with the same key, and drops the least
+// recently used entries if that makes too many. The caller must hold c.mu.
+func (c *SnippetCache) put(e *cacheEntry) {
+	if el, ok := c.entries[e.key]; ok {
+		c.removeElement(el)
+	}
+	c.entries[e.key] = c.lru.PushFront(e)
+
+	for c.lru.Len() > c.size {
+		c.removeElement(c.lru.Back())
+	}
+}
+
+func (c *SnippetCache) remove(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[key]; ok {
+		c.removeElement(el)
+	}
+}
+
+// removeElement drops el from the cache. The caller must hold c.mu.
+func (c *SnippetCache) removeElement(el *list.Element) {
+	if el == nil {
+		return
+	}
+
+	e := c.lru.Remove(el).(*cacheEntry)
+	delete(c.entries, e.key)
+	if e.snippet != nil && c.slugs[e.snippet.ID] == e.key {
+		delete(c.slugs, e.snippet.ID)
+	}
+}
+
+func copySnippet(s *Snippet) *Snippet {
+	cp := *s
+	cp.Tags = slices.Clone(s.Tags)
+	return &cp
+}
+
+func copySnippets(snippets []*Snippet) []*Snippet {
+	cp := make([]*Snippet, len(snippets))
+	for i, s := range snippets {
+		cp[i] = copySnippet(s)
+	}
+	return cp
+}
+
+func copyTagCounts(tags []*TagCount) []*TagCount {
+	cp := make([]*TagCount, len(tags))
+	for i, t := range tags {
+		tc := *t
+		cp[i] = &tc
+	}
+	return cp
+}
@@ -0,0 +1,219 @@
+package models
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"snippetbox.cozycole.net/internal/assert"
+)
+
+// countingSnippetModel serves a fixed set of snippets and counts the calls
+// which get past the cache. Methods the cache doesn't wrap aren't needed.
+type countingSnippetModel struct {
+	SnippetModelInterface
+	snippets map[string]*Snippet
+	calls    int
+}
+
+func (m *countingSnippetModel) Get(ctx context.Context, slug string) (*Snippet, error) {
+	m.calls++
+	s, ok := m.snippets[slug]
+	if !ok {
+		return nil, ErrNoRecord
+	}
+	if s.BurnAfterRead {
+		delete(m.snippets, slug)
+	}
+	return s, nil
+}
+
+func (m *countingSnippetModel) Peek(ctx context.Context, slug string) (*Snippet, error) {
+	m.calls++
+	s, ok := m.snippets[slug]
+	if !ok {
+		return nil, ErrNoRecord
+	}
+	return s, nil
+}
+
+func (m *countingSnippetModel) Latest(ctx context.Context) ([]*Snippet, error) {
+	m.calls++
+	var snippets []*Snippet
+	for _, s := range m.snippets {
+		snippets = append(snippets, s)
+	}
+	return snippets, nil
+}
+
+func (m *countingSnippetModel) TagCloud(ctx context.Context, limit int) ([]*TagCount, error) {
+	m.calls++
+	return []*TagCount{{Name: "haiku", Count: 1}}, nil
+}
+
+func (m *countingSnippetModel) Insert(ctx context.Context, userID int, title string, content string, visibility string, language string, format string, tags []string, expires time.Time, burnAfterRead bool) (string, error) {
+	return "new0000000", nil
+}
+
+func (m *countingSnippetModel) Update(ctx context.Context, id int, userID int, title string, content string, visibility string, language string, format string, tags []string, expires time.Time, burnAfterRead bool) error {
+	return nil
+}
+
+func (m *countingSnippetModel) Delete(ctx context.Context, id int) error {
+	return nil
+}
+
+func newTestCache(size int) (*SnippetCache, *countingSnippetModel, *time.Time) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	next := &countingSnippetModel{snippets: map[string]*Snippet{
+		"pond4Xk9Qa": {ID: 1, Slug: "pond4Xk9Qa", Title: "An old silent pond", Tags: []string{"haiku"}, Expires: now.Add(time.Hour)},
+		"frog5Ab8Cd": {ID: 2, Slug: "frog5Ab8Cd", Title: "A frog jumps", Expires: now.Add(30 * time.Second)},
+		"burn3VwXyZ": {ID: 3, Slug: "burn3VwXyZ", Title: "Secret", Expires: now.Add(time.Hour), BurnAfterRead: true},
+	}}
+
+	c := NewSnippetCache(next, size, time.Minute)
+	c.now = func() time.Time { return now }
+	return c, next, &now
+}
+
+func TestSnippetCache(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Hits", func(t *testing.T) {
+		c, next, _ := newTestCache(10)
+
+		for i := 0; i < 3; i++ {
+			s, err := c.Get(ctx, "pond4Xk9Qa")
+			assert.NilError(t, err)
+			assert.Equal(t, s.Title, "An old silent pond")
+		}
+		_, err := c.Peek(ctx, "pond4Xk9Qa")
+		assert.NilError(t, err)
+
+		assert.Equal(t, next.calls, 1)
+		hits, misses := c.Stats()
+		assert.Equal(t, hits, uint64(3))
+		assert.Equal(t, misses, uint64(1))
+	})
+
+	t.Run("Copies", func(t *testing.T) {
+		c, _, _ := newTestCache(10)
+
+		s, err := c.Get(ctx, "pond4Xk9Qa")
+		assert.NilError(t, err)
+		s.Title = "Changed"
+		s.Tags[0] = "changed"
+
+		s, err = c.Get(ctx, "pond4Xk9Qa")
+		assert.NilError(t, err)
+		assert.Equal(t, s.Title, "An old silent pond")
+		assert.Equal(t, s.Tags[0], "haiku")
+	})
+
+	t.Run("Misses are not cached", func(t *testing.T) {
+		c, next, _ := newTestCache(10)
+
+		for i := 0; i < 2; i++ {
+			_, err := c.Get(ctx, "nothere0Xy")
+			assert.Equal(t, err, ErrNoRecord)
+		}
+		assert.Equal(t, next.calls, 2)
+	})
+
+	t.Run("Burn after reading", func(t *testing.T) {
+		c, _, _ := newTestCache(10)
+
+		_, err := c.Peek(ctx, "burn3VwXyZ")
+		assert.NilError(t, err)
+		_, err = c.Get(ctx, "burn3VwXyZ")
+		assert.NilError(t, err)
+		_, err = c.Get(ctx, "burn3VwXyZ")
+		assert.Equal(t, err, ErrNoRecord)
+	})
+
+	t.Run("TTL and expiry", func(t *testing.T) {
+		c, next, now := newTestCache(10)
+
+		c.Get(ctx, "pond4Xk9Qa")
+		c.Get(ctx, "frog5Ab8Cd")
+		assert.Equal(t, next.calls, 2)
+
+		// The frog snippet expires before the TTL is up
+		*now = now.Add(45 * time.Second)
+		c.Get(ctx, "pond4Xk9Qa")
+		c.Get(ctx, "frog5Ab8Cd")
+		assert.Equal(t, next.calls, 3)
+
+		*now = now.Add(time.Minute)
+		c.Get(ctx, "pond4Xk9Qa")
+		assert.Equal(t, next.calls, 4)
+	})
+
+	t.Run("Least recently used is dropped", func(t *testing.T) {
+		c, next, _ := newTestCache(2)
+
+		c.Get(ctx, "pond4Xk9Qa")
+		c.Latest(ctx)
+		c.Get(ctx, "pond4Xk9Qa")
+		c.Get(ctx, "frog5Ab8Cd")
+		assert.Equal(t, next.calls, 3)
+
+		// Latest was used least recently, so it's the one which went
+		c.Get(ctx, "pond4Xk9Qa")
+		assert.Equal(t, next.calls, 3)
+		c.Latest(ctx)
+		assert.Equal(t, next.calls, 4)
+	})
+
+	t.Run("Tag cloud", func(t *testing.T) {
+		c, next, now := newTestCache(10)
+
+		for i := 0; i < 2; i++ {
+			tags, err := c.TagCloud(ctx, 30)
+			assert.NilError(t, err)
+			assert.Equal(t, len(tags), 1)
+			tags[0].Count = 100
+		}
+		assert.Equal(t, next.calls, 1)
+
+		tags, err := c.TagCloud(ctx, 30)
+		assert.NilError(t, err)
+		assert.Equal(t, tags[0].Count, 1)
+
+		// Another limit isn't answered from the cache
+		c.TagCloud(ctx, 5)
+		assert.Equal(t, next.calls, 2)
+
+		*now = now.Add(time.Minute)
+		c.TagCloud(ctx, 5)
+		assert.Equal(t, next.calls, 3)
+	})
+
+	t.Run("Invalidation", func(t *testing.T) {
+		c, next, _ := newTestCache(10)
+
+		c.Get(ctx, "pond4Xk9Qa")
+		c.Latest(ctx)
+		c.TagCloud(ctx, 30)
+		assert.Equal(t, next.calls, 3)
+
+		_, err := c.Insert(ctx, 1, "New", "New", VisibilityPublic, "plaintext", FormatPlain, nil, NoExpiry, false)
+		assert.NilError(t, err)
+		c.Get(ctx, "pond4Xk9Qa")
+		c.Latest(ctx)
+		c.TagCloud(ctx, 30)
+		assert.Equal(t, next.calls, 5)
+
+		assert.NilError(t, c.Update(ctx, 1, 1, "Edited", "Edited", VisibilityPublic, "plaintext", FormatPlain, nil, NoExpiry, false))
+		c.Get(ctx, "pond4Xk9Qa")
+		c.Latest(ctx)
+		c.TagCloud(ctx, 30)
+		assert.Equal(t, next.calls, 8)
+
+		assert.NilError(t, c.Delete(ctx, 1))
+		c.Peek(ctx, "pond4Xk9Qa")
+		c.Latest(ctx)
+		c.TagCloud(ctx, 30)
+		assert.Equal(t, next.calls, 11)
+	})
+}
@@ -8,4 +8,5 @@ var (
 	ErrNoRecord           = errors.New("models: no matching record found")
 	ErrInvalidCredentials = errors.New("models: invalid crednetials")
 	ErrDuplicateEmail     = errors.New("models: duplicate email")
+	ErrInvalidCursor      = errors.New("models: invalid cursor")
 )
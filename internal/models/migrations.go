@@ -0,0 +1,213 @@
+package models
+
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The schema is built up by numbered migrations, one set for each dialect,
+// named like 0002_create_snippets.up.sql with a matching .down.sql which
+// undoes it. A migration is never changed once it's been released; a new
+// one is added instead.
+//
+//go:embed migrations
+var migrationFiles embed.FS
+
+// A Migration is one step in building the schema.
+type Migration struct {
+	Version int
+	Name    string
+	// Applied is when the migration was applied, or the zero time if it's
+	// still pending.
+	Applied time.Time
+	up      string
+	down    string
+}
+
+// Migrator applies the migrations for its dialect to the database, keeping
+// track of which have been applied in the schema_migrations table.
+//
+// Each migration runs in a transaction, but MySQL commits as soon as it
+// sees a CREATE or DROP, so a migration which fails part way through there
+// has to be tidied up by hand.
+type Migrator struct {
+	DB      *sql.DB
+	Dialect Dialect
+}
+
+// migrations returns every migration for the dialect, in version order.
+func (m *Migrator) migrations() ([]*Migration, error) {
+	dir := path.Join("migrations", m.Dialect.String())
+
+	names, err := fs.Glob(migrationFiles, path.Join(dir, "*.up.sql"))
+	if err != nil {
+		return nil, err
+	}
+
+	var migrations []*Migration
+	for _, name := range names {
+		base := strings.TrimSuffix(path.Base(name), ".up.sql")
+
+		prefix, rest, _ := strings.Cut(base, "_")
+		version, err := strconv.Atoi(prefix)
+		if err != nil {
+			return nil, fmt.Errorf("models: bad migration name %q", name)
+		}
+
+		up, err := fs.ReadFile(migrationFiles, name)
+		if err != nil {
+			return nil, err
+		}
+		down, err := fs.ReadFile(migrationFiles, path.Join(dir, base+".down.sql"))
+		if err != nil {
+			return nil, err
+		}
+
+		migrations = append(migrations, &Migration{
+			Version: version,
+			Name:    rest,
+			up:      string(up),
+			down:    string(down),
+		})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// Status returns every migration, with when it was applied filled in for
+// those which have been.
+func (m *Migrator) Status() ([]*Migration, error) {
+	migrations, err := m.migrations()
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = m.DB.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER NOT NULL PRIMARY KEY,
+		applied TIMESTAMP NOT NULL
+	)`)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := m.DB.Query("SELECT version, applied FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[int]time.Time{}
+	for rows.Next() {
+		var version int
+		var at time.Time
+		err = rows.Scan(&version, &at)
+		if err != nil {
+			return nil, err
+		}
+		applied[version] = at
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, migration := range migrations {
+		migration.Applied = applied[migration.Version]
+	}
+	return migrations, nil
+}
+
+// Up applies every pending migration, oldest first, and returns the ones it
+// applied. It stops at the first which fails.
+func (m *Migrator) Up() ([]*Migration, error) {
+	migrations, err := m.Status()
+	if err != nil {
+		return nil, err
+	}
+
+	var applied []*Migration
+	for _, migration := range migrations {
+		if !migration.Applied.IsZero() {
+			continue
+		}
+
+		now := utcNow()
+		err = m.run(migration.up, "INSERT INTO schema_migrations (version, applied) VALUES (?, ?)", migration.Version, now)
+		if err != nil {
+			return applied, fmt.Errorf("models: migration %04d_%s: %w", migration.Version, migration.Name, err)
+		}
+		migration.Applied = now
+		applied = append(applied, migration)
+	}
+	return applied, nil
+}
+
+// Down reverts the latest applied migration and returns it, or returns nil
+// if none have been applied.
+func (m *Migrator) Down() (*Migration, error) {
+	migrations, err := m.Status()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := len(migrations) - 1; i >= 0; i-- {
+		migration := migrations[i]
+		if migration.Applied.IsZero() {
+			continue
+		}
+
+		err = m.run(migration.down, "DELETE FROM schema_migrations WHERE version = ?", migration.Version)
+		if err != nil {
+			return nil, fmt.Errorf("models: migration %04d_%s: %w", migration.Version, migration.Name, err)
+		}
+		migration.Applied = time.Time{}
+		return migration, nil
+	}
+	return nil, nil
+}
+
+// run executes script and then the statement which records it, together in
+// one transaction.
+func (m *Migrator) run(script string, record string, args ...any) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, stmt := range splitStatements(script) {
+		_, err = tx.Exec(stmt)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Exec(m.Dialect.rebind(record), args...)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// splitStatements splits a migration into its statements, so that they can
+// be run one at a time; the MySQL driver only runs several at once when the
+// DSN asks for it. Statements end with a semicolon at the end of a line.
+func splitStatements(script string) []string {
+	var stmts []string
+	for _, stmt := range strings.SplitAfter(script, ";\n") {
+		stmt = strings.TrimSpace(stmt)
+		if stmt != "" {
+			stmts = append(stmts, stmt)
+		}
+	}
+	return stmts
+}
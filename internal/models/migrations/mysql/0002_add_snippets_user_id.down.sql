@@ -0,0 +1,3 @@
+ALTER TABLE snippets DROP FOREIGN KEY fk_snippets_user;
+DROP INDEX idx_snippets_user_id ON snippets;
+ALTER TABLE snippets DROP COLUMN user_id;
\ No newline at end of file
@@ -0,0 +1,3 @@
+ALTER TABLE snippets ADD COLUMN user_id INTEGER;
+CREATE INDEX idx_snippets_user_id ON snippets(user_id);
+ALTER TABLE snippets ADD CONSTRAINT fk_snippets_user FOREIGN KEY (user_id) REFERENCES users(id);
\ No newline at end of file
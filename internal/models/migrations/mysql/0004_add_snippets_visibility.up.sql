@@ -0,0 +1 @@
+ALTER TABLE snippets ADD COLUMN visibility ENUM('public', 'unlisted', 'private') NOT NULL DEFAULT 'public';
\ No newline at end of file
@@ -0,0 +1,15 @@
+ALTER TABLE snippets ADD COLUMN slug CHAR(10) CHARACTER SET ascii COLLATE ascii_bin;
+UPDATE snippets SET slug = CONCAT(
+    SUBSTRING('ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz', FLOOR(1 + RAND() * 52), 1),
+    SUBSTRING('0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz', FLOOR(1 + RAND() * 62), 1),
+    SUBSTRING('0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz', FLOOR(1 + RAND() * 62), 1),
+    SUBSTRING('0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz', FLOOR(1 + RAND() * 62), 1),
+    SUBSTRING('0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz', FLOOR(1 + RAND() * 62), 1),
+    SUBSTRING('0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz', FLOOR(1 + RAND() * 62), 1),
+    SUBSTRING('0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz', FLOOR(1 + RAND() * 62), 1),
+    SUBSTRING('0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz', FLOOR(1 + RAND() * 62), 1),
+    SUBSTRING('0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz', FLOOR(1 + RAND() * 62), 1),
+    SUBSTRING('0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz', FLOOR(1 + RAND() * 62), 1)
+);
+ALTER TABLE snippets MODIFY slug CHAR(10) CHARACTER SET ascii COLLATE ascii_bin NOT NULL;
+ALTER TABLE snippets ADD CONSTRAINT snippets_uc_slug UNIQUE (slug);
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE snippets DROP COLUMN language;
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE snippets ADD COLUMN language VARCHAR(32) NOT NULL DEFAULT 'plaintext';
\ No newline at end of file
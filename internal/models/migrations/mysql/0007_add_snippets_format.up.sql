@@ -0,0 +1 @@
+ALTER TABLE snippets ADD COLUMN format ENUM('plain', 'markdown') NOT NULL DEFAULT 'plain';
\ No newline at end of file
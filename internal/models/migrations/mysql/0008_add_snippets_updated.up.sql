@@ -0,0 +1,3 @@
+ALTER TABLE snippets ADD COLUMN updated DATETIME;
+UPDATE snippets SET updated = created;
+ALTER TABLE snippets MODIFY updated DATETIME NOT NULL;
\ No newline at end of file
@@ -0,0 +1,11 @@
+CREATE TABLE tokens (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    user_id INTEGER NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    hash CHAR(64) CHARACTER SET ascii NOT NULL,
+    scopes VARCHAR(255) NOT NULL,
+    created DATETIME NOT NULL,
+    last_used DATETIME
+);
+ALTER TABLE tokens ADD CONSTRAINT tokens_uc_hash UNIQUE (hash);
+ALTER TABLE tokens ADD CONSTRAINT fk_tokens_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
\ No newline at end of file
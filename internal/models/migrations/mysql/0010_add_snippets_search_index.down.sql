@@ -0,0 +1 @@
+DROP INDEX ft_snippets_title_content ON snippets;
\ No newline at end of file
@@ -0,0 +1,13 @@
+CREATE TABLE tags (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    name VARCHAR(30) CHARACTER SET ascii NOT NULL
+);
+ALTER TABLE tags ADD CONSTRAINT tags_uc_name UNIQUE (name);
+CREATE TABLE snippet_tags (
+    snippet_id INTEGER NOT NULL,
+    tag_id INTEGER NOT NULL,
+    PRIMARY KEY (snippet_id, tag_id)
+);
+CREATE INDEX idx_snippet_tags_tag_id ON snippet_tags(tag_id);
+ALTER TABLE snippet_tags ADD CONSTRAINT fk_snippet_tags_snippet FOREIGN KEY (snippet_id) REFERENCES snippets(id) ON DELETE CASCADE;
+ALTER TABLE snippet_tags ADD CONSTRAINT fk_snippet_tags_tag FOREIGN KEY (tag_id) REFERENCES tags(id);
\ No newline at end of file
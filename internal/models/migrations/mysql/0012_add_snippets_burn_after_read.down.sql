@@ -0,0 +1 @@
+ALTER TABLE snippets DROP COLUMN burn_after_read;
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE users DROP COLUMN verified;
\ No newline at end of file
@@ -0,0 +1 @@
+DROP TABLE snippet_revisions;
\ No newline at end of file
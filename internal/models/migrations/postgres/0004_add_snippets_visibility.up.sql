@@ -0,0 +1 @@
+ALTER TABLE snippets ADD COLUMN visibility VARCHAR(8) NOT NULL DEFAULT 'public' CHECK (visibility IN ('public', 'unlisted', 'private'));
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE snippets DROP COLUMN slug;
\ No newline at end of file
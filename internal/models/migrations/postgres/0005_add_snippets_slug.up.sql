@@ -0,0 +1,14 @@
+ALTER TABLE snippets ADD COLUMN slug CHAR(10);
+UPDATE snippets SET slug =
+    substr('ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz', floor(random() * 52)::int + 1, 1) ||
+    substr('0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz', floor(random() * 62)::int + 1, 1) ||
+    substr('0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz', floor(random() * 62)::int + 1, 1) ||
+    substr('0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz', floor(random() * 62)::int + 1, 1) ||
+    substr('0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz', floor(random() * 62)::int + 1, 1) ||
+    substr('0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz', floor(random() * 62)::int + 1, 1) ||
+    substr('0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz', floor(random() * 62)::int + 1, 1) ||
+    substr('0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz', floor(random() * 62)::int + 1, 1) ||
+    substr('0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz', floor(random() * 62)::int + 1, 1) ||
+    substr('0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz', floor(random() * 62)::int + 1, 1);
+ALTER TABLE snippets ALTER COLUMN slug SET NOT NULL;
+ALTER TABLE snippets ADD CONSTRAINT snippets_uc_slug UNIQUE (slug);
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE snippets ADD COLUMN format VARCHAR(8) NOT NULL DEFAULT 'plain' CHECK (format IN ('plain', 'markdown'));
\ No newline at end of file
@@ -0,0 +1,3 @@
+ALTER TABLE snippets ADD COLUMN updated TIMESTAMP;
+UPDATE snippets SET updated = created;
+ALTER TABLE snippets ALTER COLUMN updated SET NOT NULL;
\ No newline at end of file
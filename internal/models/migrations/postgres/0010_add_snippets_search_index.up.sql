@@ -0,0 +1 @@
+CREATE INDEX ft_snippets_title_content ON snippets USING GIN (to_tsvector('simple', title || ' ' || content));
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE snippets ADD COLUMN burn_after_read BOOLEAN NOT NULL DEFAULT FALSE;
\ No newline at end of file
@@ -0,0 +1,3 @@
+DROP TABLE sessions;
+DROP TABLE snippets;
+DROP TABLE users;
\ No newline at end of file
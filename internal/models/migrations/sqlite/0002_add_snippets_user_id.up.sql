@@ -0,0 +1,2 @@
+ALTER TABLE snippets ADD COLUMN user_id INTEGER REFERENCES users(id);
+CREATE INDEX idx_snippets_user_id ON snippets(user_id);
\ No newline at end of file
@@ -0,0 +1,10 @@
+CREATE TABLE snippet_revisions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    snippet_id INTEGER NOT NULL REFERENCES snippets(id) ON DELETE CASCADE,
+    revision INTEGER NOT NULL,
+    user_id INTEGER NOT NULL REFERENCES users(id),
+    title TEXT NOT NULL,
+    content TEXT NOT NULL,
+    created TIMESTAMP NOT NULL,
+    CONSTRAINT snippet_revisions_uc_revision UNIQUE (snippet_id, revision)
+);
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE snippets DROP COLUMN visibility;
\ No newline at end of file
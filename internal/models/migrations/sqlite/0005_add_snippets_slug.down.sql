@@ -0,0 +1,2 @@
+DROP INDEX snippets_uc_slug;
+ALTER TABLE snippets DROP COLUMN slug;
\ No newline at end of file
@@ -0,0 +1,13 @@
+ALTER TABLE snippets ADD COLUMN slug TEXT NOT NULL DEFAULT '';
+UPDATE snippets SET slug =
+    substr('ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz', abs(random() % 52) + 1, 1) ||
+    substr('0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz', abs(random() % 62) + 1, 1) ||
+    substr('0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz', abs(random() % 62) + 1, 1) ||
+    substr('0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz', abs(random() % 62) + 1, 1) ||
+    substr('0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz', abs(random() % 62) + 1, 1) ||
+    substr('0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz', abs(random() % 62) + 1, 1) ||
+    substr('0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz', abs(random() % 62) + 1, 1) ||
+    substr('0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz', abs(random() % 62) + 1, 1) ||
+    substr('0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz', abs(random() % 62) + 1, 1) ||
+    substr('0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz', abs(random() % 62) + 1, 1);
+CREATE UNIQUE INDEX snippets_uc_slug ON snippets(slug);
\ No newline at end of file
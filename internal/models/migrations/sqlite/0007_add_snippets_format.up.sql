@@ -0,0 +1 @@
+ALTER TABLE snippets ADD COLUMN format TEXT NOT NULL DEFAULT 'plain' CHECK (format IN ('plain', 'markdown'));
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE snippets DROP COLUMN updated;
\ No newline at end of file
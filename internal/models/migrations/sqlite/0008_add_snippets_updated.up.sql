@@ -0,0 +1,2 @@
+ALTER TABLE snippets ADD COLUMN updated TIMESTAMP NOT NULL DEFAULT '1970-01-01 00:00:00';
+UPDATE snippets SET updated = created;
\ No newline at end of file
@@ -0,0 +1,11 @@
+CREATE TABLE tags (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL,
+    CONSTRAINT tags_uc_name UNIQUE (name)
+);
+CREATE TABLE snippet_tags (
+    snippet_id INTEGER NOT NULL REFERENCES snippets(id) ON DELETE CASCADE,
+    tag_id INTEGER NOT NULL REFERENCES tags(id),
+    PRIMARY KEY (snippet_id, tag_id)
+);
+CREATE INDEX idx_snippet_tags_tag_id ON snippet_tags(tag_id);
\ No newline at end of file
@@ -0,0 +1,2 @@
+ALTER TABLE users ADD COLUMN verified BOOLEAN NOT NULL DEFAULT FALSE;
+UPDATE users SET verified = TRUE;
\ No newline at end of file
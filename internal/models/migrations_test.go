@@ -0,0 +1,94 @@
+package models
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"snippetbox.cozycole.net/internal/assert"
+)
+
+func TestMigrator(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	// newTestDB has already applied every migration
+	db, dialect := newTestDB(t)
+
+	m := Migrator{DB: db, Dialect: dialect}
+
+	migrations, err := m.Status()
+	assert.NilError(t, err)
+	assert.Equal(t, len(migrations) > 0, true)
+	for i, migration := range migrations {
+		assert.Equal(t, migration.Version, i+1)
+		assert.Equal(t, migration.Applied.IsZero(), false)
+	}
+	latest := migrations[len(migrations)-1]
+
+	applied, err := m.Up()
+	assert.NilError(t, err)
+	assert.Equal(t, len(applied), 0)
+
+	reverted, err := m.Down()
+	assert.NilError(t, err)
+	assert.Equal(t, reverted.Version, latest.Version)
+
+	migrations, err = m.Status()
+	assert.NilError(t, err)
+	assert.Equal(t, migrations[len(migrations)-1].Applied.IsZero(), true)
+
+	applied, err = m.Up()
+	assert.NilError(t, err)
+	assert.Equal(t, len(applied), 1)
+	assert.Equal(t, applied[0].Version, latest.Version)
+	assert.Equal(t, applied[0].Name, latest.Name)
+}
+
+func TestMigratorBaseline(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	// A database set up by hand from the original schema, before there
+	// were migrations, with a snippet in it
+	db, dialect, migrator := openTestDB(t)
+	execTestdata(t, db, dialect, "baseline.sql")
+
+	_, err := migrator.Up()
+	assert.NilError(t, err)
+
+	ctx := context.Background()
+	m := SnippetModel{DB: db, Dialect: dialect}
+
+	// It's given a slug, so links to its old numeric ID can be redirected
+	s, err := m.GetByID(ctx, 1)
+	assert.NilError(t, err)
+	assert.Equal(t, ValidSlug(s.Slug), true)
+	assert.Equal(t, strings.Trim(s.Slug, "0123456789") != "", true)
+	assert.Equal(t, s.Title, "An old silent pond")
+
+	s, err = m.Get(ctx, s.Slug)
+	assert.NilError(t, err)
+	assert.Equal(t, s.UserID, 0)
+	assert.Equal(t, s.Author, "")
+	assert.Equal(t, s.Visibility, VisibilityPublic)
+	assert.Equal(t, s.Language, "plaintext")
+	assert.Equal(t, s.Format, FormatPlain)
+	assert.Equal(t, s.Updated, s.Created)
+	assert.Equal(t, s.BurnAfterRead, false)
+
+	latest, err := m.Latest(ctx)
+	assert.NilError(t, err)
+	assert.Equal(t, len(latest), 1)
+}
+
+func TestSplitStatements(t *testing.T) {
+	stmts := splitStatements("CREATE TABLE a (\n    id INTEGER\n);\nCREATE INDEX a_id ON a (id);\n\nDROP TABLE b;")
+
+	assert.Equal(t, len(stmts), 3)
+	assert.Equal(t, stmts[0], "CREATE TABLE a (\n    id INTEGER\n);")
+	assert.Equal(t, stmts[1], "CREATE INDEX a_id ON a (id);")
+	assert.Equal(t, stmts[2], "DROP TABLE b;")
+}
@@ -0,0 +1,7 @@
+package mocks
+
+type SessionModel struct{}
+
+func (m *SessionModel) Active() (int, error) {
+	return 2, nil
+}
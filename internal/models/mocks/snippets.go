@@ -1,32 +1,265 @@
 package mocks
 
 import (
+	"context"
+	"strings"
 	"time"
 
 	"snippetbox.cozycole.net/internal/models"
 )
 
 var mockSnippet = &models.Snippet{
-	ID:      1,
-	Title:   "An old silent pond",
-	Content: "An old silent pond...",
-	Created: time.Now(),
-	Expires: time.Now(),
+	ID:         1,
+	Slug:       "pond4Xk9Qa",
+	UserID:     1,
+	Author:     "Alice Smith",
+	Title:      "An old silent pond",
+	Content:    "An old silent pond...",
+	Visibility: models.VisibilityPublic,
+	Language:   "plaintext",
+	Format:     models.FormatPlain,
+	Created:    time.Now(),
+	Updated:    time.Now(),
+	Expires:    time.Now().Add(24 * time.Hour),
+	Tags:       []string{"haiku", "nature"},
 }
 
+// mockOtherSnippet belongs to a user other than the one the mock
+// UserModel authenticates, for exercising ownership checks.
+var mockOtherSnippet = &models.Snippet{
+	ID:         3,
+	Slug:       "forest7BcD",
+	UserID:     2,
+	Author:     "Bob Brown",
+	Title:      "Over the wintry forest",
+	Content:    "Over the wintry forest, winds howl in rage...",
+	Visibility: models.VisibilityPublic,
+	Language:   "plaintext",
+	Format:     models.FormatPlain,
+	Created:    time.Now(),
+	Updated:    time.Now(),
+	Expires:    time.Now().Add(24 * time.Hour),
+}
+
+// mockPrivateSnippet also belongs to another user, but is private so only
+// they may see it.
+var mockPrivateSnippet = &models.Snippet{
+	ID:         4,
+	Slug:       "autumn2EfG",
+	UserID:     2,
+	Author:     "Bob Brown",
+	Title:      "First autumn morning",
+	Content:    "First autumn morning, the mirror I stare into...",
+	Visibility: models.VisibilityPrivate,
+	Language:   "plaintext",
+	Format:     models.FormatPlain,
+	Created:    time.Now(),
+	Updated:    time.Now(),
+	Expires:    time.Now().Add(24 * time.Hour),
+}
+
+// mockMarkdownSnippet is written in markdown, including some markup that
+// must not survive rendering.
+var mockMarkdownSnippet = &models.Snippet{
+	ID:         5,
+	Slug:       "notes8RsTu",
+	UserID:     1,
+	Author:     "Alice Smith",
+	Title:      "Making tea",
+	Content:    "# Making tea\n\n<script>alert(1)</script>\n\n```bash\necho boil\n```",
+	Visibility: models.VisibilityUnlisted,
+	Language:   "markdown",
+	Format:     models.FormatMarkdown,
+	Created:    time.Now(),
+	Updated:    time.Now(),
+	Expires:    time.Now().Add(24 * time.Hour),
+}
+
+// mockNewSnippet is what Insert pretends to have created.
+var mockNewSnippet = &models.Snippet{
+	ID:         6,
+	Slug:       "new5HjKmNp",
+	UserID:     1,
+	Author:     "Alice Smith",
+	Title:      "A new title",
+	Content:    "An old silent pond...",
+	Visibility: models.VisibilityPublic,
+	Language:   "plaintext",
+	Format:     models.FormatPlain,
+	Created:    time.Now(),
+	Updated:    time.Now(),
+	Expires:    time.Now().Add(24 * time.Hour),
+}
+
+// mockBurnSnippet belongs to another user and is deleted once it's read.
+var mockBurnSnippet = &models.Snippet{
+	ID:            7,
+	Slug:          "burn3VwXyZ",
+	UserID:        2,
+	Author:        "Bob Brown",
+	Title:         "The password",
+	Content:       "hunter2",
+	Visibility:    models.VisibilityUnlisted,
+	Language:      "plaintext",
+	Format:        models.FormatPlain,
+	Created:       time.Now(),
+	Updated:       time.Now(),
+	Expires:       time.Now().Add(24 * time.Hour),
+	BurnAfterRead: true,
+}
+
+var mockRevisions = []*models.SnippetRevision{
+	{
+		ID:        2,
+		SnippetID: 1,
+		Revision:  2,
+		UserID:    1,
+		Author:    "Alice Smith",
+		Title:     "An old silent pond",
+		Content:   "An old silent pond...",
+		Created:   time.Now(),
+	},
+	{
+		ID:        1,
+		SnippetID: 1,
+		Revision:  1,
+		UserID:    1,
+		Author:    "Alice Smith",
+		Title:     "An old silent pond",
+		Content:   "An old pond...",
+		Created:   time.Now(),
+	},
+}
+
+// SlowSlug is the slug of a snippet which the mock SnippetModel takes too
+// long to look up, as if the database were overloaded.
+const SlowSlug = "s1owQuery0"
+
 type SnippetModel struct{}
 
-func (m *SnippetModel) Insert(title string, content string, expires int) (int, error) {
-	return 2, nil
+func (m *SnippetModel) Insert(ctx context.Context, userID int, title string, content string, visibility string, language string, format string, tags []string, expires time.Time, burnAfterRead bool) (string, error) {
+	return mockNewSnippet.Slug, nil
 }
-func (m *SnippetModel) Get(id int) (*models.Snippet, error) {
+func (m *SnippetModel) Get(ctx context.Context, slug string) (*models.Snippet, error) {
+	switch slug {
+	case mockSnippet.Slug:
+		return mockSnippet, nil
+	case mockOtherSnippet.Slug:
+		return mockOtherSnippet, nil
+	case mockPrivateSnippet.Slug:
+		return mockPrivateSnippet, nil
+	case mockMarkdownSnippet.Slug:
+		return mockMarkdownSnippet, nil
+	case mockNewSnippet.Slug:
+		return mockNewSnippet, nil
+	case mockBurnSnippet.Slug:
+		return mockBurnSnippet, nil
+	case SlowSlug:
+		return nil, context.DeadlineExceeded
+	default:
+		return nil, models.ErrNoRecord
+	}
+}
+func (m *SnippetModel) Peek(ctx context.Context, slug string) (*models.Snippet, error) {
+	return m.Get(ctx, slug)
+}
+func (m *SnippetModel) GetByID(ctx context.Context, id int) (*models.Snippet, error) {
 	switch id {
 	case 1:
 		return mockSnippet, nil
+	case 3:
+		return mockOtherSnippet, nil
+	case 4:
+		return mockPrivateSnippet, nil
+	case 5:
+		return mockMarkdownSnippet, nil
+	case 6:
+		return mockNewSnippet, nil
+	case 7:
+		return mockBurnSnippet, nil
 	default:
 		return nil, models.ErrNoRecord
 	}
 }
-func (m *SnippetModel) Latest() ([]*models.Snippet, error) {
+func (m *SnippetModel) Latest(ctx context.Context) ([]*models.Snippet, error) {
+	return []*models.Snippet{mockSnippet}, nil
+}
+func (m *SnippetModel) List(ctx context.Context, limit int, offset int) ([]*models.Snippet, error) {
+	snippets := []*models.Snippet{mockSnippet, mockOtherSnippet}
+	if offset >= len(snippets) {
+		return []*models.Snippet{}, nil
+	}
+	snippets = snippets[offset:]
+	if limit < len(snippets) {
+		snippets = snippets[:limit]
+	}
+	return snippets, nil
+}
+func (m *SnippetModel) Search(ctx context.Context, query string, page int) ([]*models.Snippet, bool, error) {
+	if page == 1 && strings.Contains(strings.ToLower(mockSnippet.Content), strings.ToLower(query)) {
+		return []*models.Snippet{mockSnippet}, false, nil
+	}
+	return []*models.Snippet{}, false, nil
+}
+func (m *SnippetModel) Browse(ctx context.Context, before models.Cursor, limit int) ([]*models.Snippet, error) {
+	if !before.IsZero() {
+		return []*models.Snippet{}, nil
+	}
+	return m.List(ctx, limit, 0)
+}
+func (m *SnippetModel) Archive(ctx context.Context, month time.Time, before models.Cursor, limit int) ([]*models.Snippet, error) {
+	if !before.IsZero() || month.Year() != mockSnippet.Created.UTC().Year() || month.Month() != mockSnippet.Created.UTC().Month() {
+		return []*models.Snippet{}, nil
+	}
 	return []*models.Snippet{mockSnippet}, nil
 }
+func (m *SnippetModel) ArchiveMonths(ctx context.Context) ([]*models.ArchiveMonth, error) {
+	month := time.Date(mockSnippet.Created.UTC().Year(), mockSnippet.Created.UTC().Month(), 1, 0, 0, 0, 0, time.UTC)
+	return []*models.ArchiveMonth{{Month: month, Count: 1}}, nil
+}
+func (m *SnippetModel) ByTag(ctx context.Context, tag string, before models.Cursor, limit int) ([]*models.Snippet, error) {
+	for _, t := range mockSnippet.Tags {
+		if t == tag && before.IsZero() {
+			return []*models.Snippet{mockSnippet}, nil
+		}
+	}
+	return []*models.Snippet{}, nil
+}
+func (m *SnippetModel) TagCloud(ctx context.Context, limit int) ([]*models.TagCount, error) {
+	return []*models.TagCount{{Name: "haiku", Count: 3}, {Name: "nature", Count: 1}}, nil
+}
+func (m *SnippetModel) ByUser(ctx context.Context, userID int) ([]*models.Snippet, error) {
+	switch userID {
+	case 1:
+		return []*models.Snippet{mockSnippet}, nil
+	default:
+		return []*models.Snippet{}, nil
+	}
+}
+func (m *SnippetModel) Update(ctx context.Context, id int, userID int, title string, content string, visibility string, language string, format string, tags []string, expires time.Time, burnAfterRead bool) error {
+	switch id {
+	case 1, 3, 4, 5, 6, 7:
+		return nil
+	default:
+		return models.ErrNoRecord
+	}
+}
+func (m *SnippetModel) Delete(ctx context.Context, id int) error {
+	switch id {
+	case 1, 3, 4, 5, 6, 7:
+		return nil
+	default:
+		return models.ErrNoRecord
+	}
+}
+func (m *SnippetModel) DeleteExpired(ctx context.Context, limit int) (int, error) {
+	return 0, nil
+}
+func (m *SnippetModel) Revisions(ctx context.Context, snippetID int) ([]*models.SnippetRevision, error) {
+	switch snippetID {
+	case 1:
+		return mockRevisions, nil
+	default:
+		return []*models.SnippetRevision{}, nil
+	}
+}
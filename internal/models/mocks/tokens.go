@@ -0,0 +1,66 @@
+package mocks
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"snippetbox.cozycole.net/internal/models"
+)
+
+// Plaintexts of the tokens the mock TokenModel accepts. Both belong to the
+// mock user.
+const (
+	ReadToken  = "sbx_read"
+	WriteToken = "sbx_write"
+)
+
+var mockTokens = []*models.Token{
+	{
+		ID:      1,
+		UserID:  1,
+		Name:    "Laptop",
+		Scopes:  []string{models.ScopeRead},
+		Created: time.Now(),
+	},
+	{
+		ID:       2,
+		UserID:   1,
+		Name:     "Deploy script",
+		Scopes:   []string{models.ScopeRead, models.ScopeWrite},
+		Created:  time.Now(),
+		LastUsed: sql.NullTime{Time: time.Now(), Valid: true},
+	},
+}
+
+type TokenModel struct{}
+
+func (m *TokenModel) Insert(ctx context.Context, userID int, name string, scopes []string) (string, error) {
+	return "sbx_new", nil
+}
+func (m *TokenModel) Authenticate(ctx context.Context, plaintext string) (*models.Token, error) {
+	switch plaintext {
+	case ReadToken:
+		return mockTokens[0], nil
+	case WriteToken:
+		return mockTokens[1], nil
+	default:
+		return nil, models.ErrInvalidCredentials
+	}
+}
+func (m *TokenModel) ByUser(ctx context.Context, userID int) ([]*models.Token, error) {
+	switch userID {
+	case 1:
+		return mockTokens, nil
+	default:
+		return []*models.Token{}, nil
+	}
+}
+func (m *TokenModel) Delete(ctx context.Context, id int, userID int) error {
+	for _, t := range mockTokens {
+		if t.ID == id && t.UserID == userID {
+			return nil
+		}
+	}
+	return models.ErrNoRecord
+}
@@ -1,48 +1,76 @@
 package mocks
 
 import (
+	"context"
 	"time"
 
 	"snippetbox.cozycole.net/internal/models"
 )
 
+// The mock users both have the password pa$$word. Alice, with ID 1, has
+// verified her email address and Carol, with ID 2, hasn't.
 type UserModel struct{}
 
-func (m *UserModel) Insert(name, email, password string) error {
+func (m *UserModel) Insert(ctx context.Context, name, email, password string) (int, error) {
 	switch email {
 	case "dupe@example.com":
-		return models.ErrDuplicateEmail
+		return 0, models.ErrDuplicateEmail
 	default:
-		return nil
+		return 3, nil
 	}
 }
-func (m *UserModel) Authenticate(email, password string) (int, error) {
-	if email == "alice@example.com" && password == "pa$$word" {
+func (m *UserModel) Authenticate(ctx context.Context, email, password string) (int, error) {
+	if password != "pa$$word" {
+		return 0, models.ErrInvalidCredentials
+	}
+	switch email {
+	case "alice@example.com":
 		return 1, nil
+	case "carol@example.com":
+		return 2, nil
+	default:
+		return 0, models.ErrInvalidCredentials
 	}
-	return 0, models.ErrInvalidCredentials
 }
-func (m *UserModel) Exists(id int) (bool, error) {
+func (m *UserModel) Exists(ctx context.Context, id int) (bool, error) {
 	switch id {
-	case 1:
+	case 1, 2:
 		return true, nil
 	default:
 		return false, nil
 	}
 }
 
-func (m *UserModel) Get(id int) (*models.User, error) {
-	if id == 1 {
+func (m *UserModel) Get(ctx context.Context, id int) (*models.User, error) {
+	switch id {
+	case 1:
+		return &models.User{
+			ID:       id,
+			Name:     "Alice Smith",
+			Email:    "alice@example.com",
+			Created:  time.Now(),
+			Verified: true,
+		}, nil
+	case 2:
 		return &models.User{
 			ID:      id,
-			Name:    "Alice Smith",
-			Email:   "alice@example.com",
+			Name:    "Carol White",
+			Email:   "carol@example.com",
 			Created: time.Now(),
 		}, nil
 	}
 	return nil, models.ErrNoRecord
 }
 
-func (m *UserModel) UpdatePassword(id int, password string) error {
+func (m *UserModel) UpdatePassword(ctx context.Context, id int, password string) error {
 	return nil
 }
+
+func (m *UserModel) Verify(ctx context.Context, id int) error {
+	switch id {
+	case 1, 2:
+		return nil
+	default:
+		return models.ErrNoRecord
+	}
+}
@@ -0,0 +1,108 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// SessionModelInterface reads the sessions table, which is written by the
+// session manager's store rather than by this package.
+type SessionModelInterface interface {
+	Active() (int, error)
+}
+
+type SessionModel struct {
+	DB      *sql.DB
+	Dialect Dialect
+}
+
+// Active returns the number of sessions which haven't expired. Expired ones
+// stay in the table until the store's cleanup deletes them.
+func (m *SessionModel) Active() (int, error) {
+	stmt := `SELECT COUNT(*) FROM sessions WHERE expiry > ?`
+
+	var n int
+	err := m.DB.QueryRow(m.Dialect.rebind(stmt), time.Now().UTC()).Scan(&n)
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// SessionStore keeps the session manager's sessions in the sessions table of
+// an SQLite or PostgreSQL database, as mysqlstore does for MySQL. Expired
+// sessions are deleted every five minutes until StopCleanup is called.
+type SessionStore struct {
+	db          *sql.DB
+	dialect     Dialect
+	stopCleanup chan struct{}
+	done        chan struct{}
+}
+
+// NewSessionStore returns a SessionStore for db and starts its cleanup.
+func NewSessionStore(db *sql.DB, dialect Dialect) *SessionStore {
+	s := &SessionStore{
+		db:          db,
+		dialect:     dialect,
+		stopCleanup: make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+	go s.cleanup(5 * time.Minute)
+	return s
+}
+
+// Find returns the data for the session with the given token. If there's no
+// such session, or it has expired, found is false.
+func (s *SessionStore) Find(token string) (b []byte, found bool, err error) {
+	stmt := "SELECT data FROM sessions WHERE token = ? AND expiry > ?"
+
+	err = s.db.QueryRow(s.dialect.rebind(stmt), token, time.Now().UTC()).Scan(&b)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, err
+	}
+	return b, true, nil
+}
+
+// Commit adds the session with the given token, or replaces it if it
+// already exists.
+func (s *SessionStore) Commit(token string, b []byte, expiry time.Time) error {
+	stmt := `INSERT INTO sessions (token, data, expiry) VALUES(?, ?, ?)
+	ON CONFLICT (token) DO UPDATE SET data = excluded.data, expiry = excluded.expiry`
+
+	_, err := s.db.Exec(s.dialect.rebind(stmt), token, b, expiry.UTC())
+	return err
+}
+
+// Delete removes the session with the given token, if there is one.
+func (s *SessionStore) Delete(token string) error {
+	_, err := s.db.Exec(s.dialect.rebind("DELETE FROM sessions WHERE token = ?"), token)
+	return err
+}
+
+// StopCleanup stops deleting expired sessions, and waits for a delete which
+// is under way to finish, so the database can be closed safely.
+func (s *SessionStore) StopCleanup() {
+	close(s.stopCleanup)
+	<-s.done
+}
+
+func (s *SessionStore) cleanup(interval time.Duration) {
+	defer close(s.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			// There's nowhere to report the error, and the next tick will
+			// try again anyway
+			s.db.Exec(s.dialect.rebind("DELETE FROM sessions WHERE expiry < ?"), time.Now().UTC())
+		case <-s.stopCleanup:
+			return
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"snippetbox.cozycole.net/internal/assert"
+)
+
+func TestSessionModelActive(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db, dialect := newTestDB(t)
+
+	now := time.Now().UTC()
+	_, err := db.Exec(dialect.rebind(`INSERT INTO sessions (token, data, expiry) VALUES
+		('live', ?, ?),
+		('expired', ?, ?)`), []byte{}, now.Add(time.Hour), []byte{}, now.Add(-time.Hour))
+	assert.NilError(t, err)
+
+	m := SessionModel{DB: db, Dialect: dialect}
+
+	n, err := m.Active()
+	assert.NilError(t, err)
+	assert.Equal(t, n, 1)
+}
+
+func TestSessionStore(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db, dialect := newTestDB(t)
+	if dialect == MySQL {
+		t.Skip("models: MySQL sessions are kept by mysqlstore")
+	}
+
+	s := NewSessionStore(db, dialect)
+	defer s.StopCleanup()
+
+	now := time.Now()
+
+	assert.NilError(t, s.Commit("live", []byte("first"), now.Add(time.Hour)))
+	assert.NilError(t, s.Commit("live", []byte("second"), now.Add(time.Hour)))
+	assert.NilError(t, s.Commit("expired", []byte("old"), now.Add(-time.Hour)))
+
+	b, found, err := s.Find("live")
+	assert.NilError(t, err)
+	assert.Equal(t, found, true)
+	assert.Equal(t, string(b), "second")
+
+	_, found, err = s.Find("expired")
+	assert.NilError(t, err)
+	assert.Equal(t, found, false)
+
+	assert.NilError(t, s.Delete("live"))
+
+	_, found, err = s.Find("live")
+	assert.NilError(t, err)
+	assert.Equal(t, found, false)
+}
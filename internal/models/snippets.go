@@ -1,54 +1,377 @@
 package models
 
 import (
+	"context"
+	"crypto/rand"
 	"database/sql"
 	"errors"
+	"fmt"
+	"strconv"
+	"strings"
 	"time"
 )
 
+// Snippet visibility levels. Public snippets are listed on the home page,
+// unlisted ones can only be reached by someone who has the link, and private
+// ones can only be seen by their owner.
+const (
+	VisibilityPublic   = "public"
+	VisibilityUnlisted = "unlisted"
+	VisibilityPrivate  = "private"
+)
+
+// Snippet formats. Plain snippets are shown as (highlighted) code, markdown
+// ones are rendered to HTML.
+const (
+	FormatPlain    = "plain"
+	FormatMarkdown = "markdown"
+)
+
+// NoExpiry is the expiry time stored for snippets which are kept forever.
+// It's the latest time a MySQL DATETIME can hold, so every query which
+// checks the expiry keeps working without a special case.
+var NoExpiry = time.Date(9999, 12, 31, 23, 59, 59, 0, time.UTC)
+
+// A Snippet's UserID is 0 and its Author empty if it was created before
+// snippets had owners. Nobody can edit or delete those.
 type Snippet struct {
-	ID      int
-	Title   string
-	Content string
+	ID            int       `json:"-"`
+	Slug          string    `json:"id"`
+	UserID        int       `json:"-"`
+	Author        string    `json:"author"`
+	Title         string    `json:"title"`
+	Content       string    `json:"content"`
+	Visibility    string    `json:"visibility"`
+	Language      string    `json:"language"`
+	Format        string    `json:"format"`
+	Created       time.Time `json:"created"`
+	Updated       time.Time `json:"updated"`
+	Expires       time.Time `json:"expires"`
+	BurnAfterRead bool      `json:"burn_after_read"`
+	Tags          []string  `json:"tags"`
+}
+
+// Permanent reports whether the snippet never expires.
+func (s *Snippet) Permanent() bool {
+	return !s.Expires.Before(NoExpiry)
+}
+
+// A SnippetRevision is a snapshot of a snippet's title and content as saved
+// by one create or edit. Revisions are numbered from 1 for each snippet.
+type SnippetRevision struct {
+	ID        int
+	SnippetID int
+	Revision  int
+	UserID    int
+	Author    string
+	Title     string
+	Content   string
+	Created   time.Time
+}
+
+// A Cursor marks a position in the list of snippets, which is ordered by
+// creation time and then ID, newest first. Unlike an offset it stays put
+// when new snippets are added, and the database can seek straight to it
+// using the created index.
+type Cursor struct {
 	Created time.Time
-	Expires time.Time
+	ID      int
+}
+
+// IsZero reports whether c is the zero Cursor, which is before nothing and
+// so means to start from the newest snippet.
+func (c Cursor) IsZero() bool {
+	return c.ID == 0
+}
+
+// CursorAfter returns the cursor for continuing a list after s.
+func CursorAfter(s *Snippet) Cursor {
+	return Cursor{Created: s.Created, ID: s.ID}
+}
+
+// String encodes c for use in a URL. ParseCursor reverses it.
+func (c Cursor) String() string {
+	return fmt.Sprintf("%d-%d", c.Created.Unix(), c.ID)
+}
+
+// ParseCursor decodes a cursor produced by Cursor.String.
+func ParseCursor(s string) (Cursor, error) {
+	created, id, ok := strings.Cut(s, "-")
+	if !ok {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	secs, err := strconv.ParseInt(created, 10, 64)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+	n, err := strconv.Atoi(id)
+	if err != nil || n < 1 {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	return Cursor{Created: time.Unix(secs, 0).UTC(), ID: n}, nil
+}
+
+// An ArchiveMonth is a month in which public snippets that haven't expired
+// yet were created.
+type ArchiveMonth struct {
+	Month time.Time
+	Count int
+}
+
+// A TagCount is a tag and how many unexpired public snippets have it.
+type TagCount struct {
+	Name  string
+	Count int
 }
 
 type SnippetModelInterface interface {
-	Insert(title string, content string, expires int) (int, error)
-	Get(id int) (*Snippet, error)
-	Latest() ([]*Snippet, error)
+	Insert(ctx context.Context, userID int, title string, content string, visibility string, language string, format string, tags []string, expires time.Time, burnAfterRead bool) (string, error)
+	Get(ctx context.Context, slug string) (*Snippet, error)
+	Peek(ctx context.Context, slug string) (*Snippet, error)
+	GetByID(ctx context.Context, id int) (*Snippet, error)
+	Latest(ctx context.Context) ([]*Snippet, error)
+	List(ctx context.Context, limit int, offset int) ([]*Snippet, error)
+	Search(ctx context.Context, query string, page int) ([]*Snippet, bool, error)
+	Browse(ctx context.Context, before Cursor, limit int) ([]*Snippet, error)
+	Archive(ctx context.Context, month time.Time, before Cursor, limit int) ([]*Snippet, error)
+	ArchiveMonths(ctx context.Context) ([]*ArchiveMonth, error)
+	ByTag(ctx context.Context, tag string, before Cursor, limit int) ([]*Snippet, error)
+	TagCloud(ctx context.Context, limit int) ([]*TagCount, error)
+	ByUser(ctx context.Context, userID int) ([]*Snippet, error)
+	Update(ctx context.Context, id int, userID int, title string, content string, visibility string, language string, format string, tags []string, expires time.Time, burnAfterRead bool) error
+	Delete(ctx context.Context, id int) error
+	DeleteExpired(ctx context.Context, limit int) (int, error)
+	Revisions(ctx context.Context, snippetID int) ([]*SnippetRevision, error)
 }
 
 type SnippetModel struct {
-	DB *sql.DB
+	DB      *sql.DB
+	Dialect Dialect
+	// Timeout limits how long each call may spend in the database. Zero
+	// means no limit beyond the caller's context.
+	Timeout time.Duration
+}
+
+// utcNow returns the current time in UTC to the second, which is all a MySQL
+// DATETIME holds, so that every database stores the same times. It's passed
+// to the queries rather than using the database's clock, as each has its
+// own function for it.
+func utcNow() time.Time {
+	return time.Now().UTC().Truncate(time.Second)
+}
+
+// Slugs are the public identifiers used in snippet URLs. They're random so
+// that snippets can't be found by counting upwards like the numeric IDs.
+const (
+	slugAlphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+	slugLength   = 10
+)
+
+func newSlug() (string, error) {
+	slug := make([]byte, 0, slugLength)
+	buf := make([]byte, 2*slugLength)
+
+	for len(slug) < slugLength {
+		_, err := rand.Read(buf)
+		if err != nil {
+			return "", err
+		}
+		for _, b := range buf {
+			// Discard bytes past the largest multiple of 62 so that every
+			// character is equally likely
+			if int(b) >= 4*len(slugAlphabet) {
+				continue
+			}
+			slug = append(slug, slugAlphabet[int(b)%len(slugAlphabet)])
+			if len(slug) == slugLength {
+				break
+			}
+		}
+		// An all digit slug would be mistaken for a legacy numeric ID
+		if len(slug) == slugLength && strings.Trim(string(slug), "0123456789") == "" {
+			slug = slug[:0]
+		}
+	}
+	return string(slug), nil
+}
+
+// ValidSlug reports whether s has the shape of a snippet slug, so obviously
+// bad values can be rejected without a database lookup.
+func ValidSlug(s string) bool {
+	if len(s) != slugLength {
+		return false
+	}
+	for _, c := range s {
+		if !strings.ContainsRune(slugAlphabet, c) {
+			return false
+		}
+	}
+	return strings.Trim(s, "0123456789") != ""
+}
+
+// Insert stores a new snippet under a freshly generated slug and returns the
+// slug.
+func (m *SnippetModel) Insert(ctx context.Context, userID int, title string, content string, visibility string, language string, format string, tags []string, expires time.Time, burnAfterRead bool) (string, error) {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	// A slug collision is astronomically unlikely, but if it happens just
+	// try again with a new one
+	for attempt := 0; ; attempt++ {
+		slug, err := newSlug()
+		if err != nil {
+			return "", err
+		}
+
+		err = m.insert(ctx, slug, userID, title, content, visibility, language, format, tags, expires, burnAfterRead)
+		if err != nil {
+			if isDuplicate(err, slugKey) && attempt < 3 {
+				continue
+			}
+			return "", err
+		}
+		return slug, nil
+	}
 }
 
-func (m *SnippetModel) Insert(title string, content string, expires int) (int, error) {
-	stmt := `INSERT INTO snippets (title, content, created, expires)
-	VALUES(?, ?, UTC_TIMESTAMP(), DATE_ADD(UTC_TIMESTAMP(), INTERVAL ? DAY))`
-	// returns an sql.Result type containing basic methods about the executed statement
-	result, err := m.DB.Exec(stmt, title, content, expires)
+func (m *SnippetModel) insert(ctx context.Context, slug string, userID int, title string, content string, visibility string, language string, format string, tags []string, expires time.Time, burnAfterRead bool) error {
+	// The snippet and its first revision are written together so the history
+	// is never missing the original version
+	tx, err := m.DB.BeginTx(ctx, nil)
 	if err != nil {
-		return 0, err
+		return err
 	}
+	defer tx.Rollback()
 
-	id, err := result.LastInsertId()
+	stmt := `INSERT INTO snippets (slug, user_id, title, content, visibility, language, format, created, updated, expires, burn_after_read)
+	VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	now := utcNow()
+	id, err := m.Dialect.insertID(ctx, tx, stmt, slug, userID, title, content, visibility, language, format, now, now, expires.UTC().Truncate(time.Second), burnAfterRead)
 	if err != nil {
-		return 0, err
+		return err
+	}
+
+	stmt = `INSERT INTO snippet_revisions (snippet_id, revision, user_id, title, content, created)
+	SELECT id, 1, user_id, title, content, created FROM snippets WHERE id = ?`
+	_, err = tx.ExecContext(ctx, m.Dialect.rebind(stmt), id)
+	if err != nil {
+		return err
+	}
+
+	err = m.setTags(ctx, tx, id, tags)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// setTags replaces the tags on a snippet, creating any tags which don't
+// exist yet.
+func (m *SnippetModel) setTags(ctx context.Context, tx *sql.Tx, snippetID int, tags []string) error {
+	_, err := tx.ExecContext(ctx, m.Dialect.rebind("DELETE FROM snippet_tags WHERE snippet_id = ?"), snippetID)
+	if err != nil {
+		return err
+	}
+
+	for _, tag := range tags {
+		tagID, err := m.tagID(ctx, tx, tag)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, m.Dialect.rebind("INSERT INTO snippet_tags (snippet_id, tag_id) VALUES(?, ?)"), snippetID, tagID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tagID returns the id of the tag with the given name, creating it if it
+// doesn't exist yet.
+func (m *SnippetModel) tagID(ctx context.Context, tx *sql.Tx, name string) (int, error) {
+	if m.Dialect == MySQL {
+		// LAST_INSERT_ID(id) makes LastInsertId return the existing tag's id
+		// when the name is already taken
+		return m.Dialect.insertID(ctx, tx, "INSERT INTO tags (name) VALUES(?) ON DUPLICATE KEY UPDATE id = LAST_INSERT_ID(id)", name)
+	}
+
+	// Setting the name to itself changes nothing, but unlike DO NOTHING it
+	// still returns the existing tag's id
+	stmt := "INSERT INTO tags (name) VALUES(?) ON CONFLICT (name) DO UPDATE SET name = excluded.name RETURNING id"
+
+	var id int
+	err := tx.QueryRowContext(ctx, m.Dialect.rebind(stmt), name).Scan(&id)
+	return id, err
+}
+
+// Get returns the snippet with the given slug, for showing to someone. A
+// snippet which burns after reading is deleted as it's returned, so it can
+// only ever be got once.
+func (m *SnippetModel) Get(ctx context.Context, slug string) (*Snippet, error) {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	s, err := m.Peek(ctx, slug)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.BurnAfterRead {
+		// Whoever deletes the row is the one reader. Anyone who looked it up
+		// at the same time loses the race and is told it doesn't exist.
+		result, err := m.DB.ExecContext(ctx, m.Dialect.rebind("DELETE FROM snippets WHERE id = ?"), s.ID)
+		if err != nil {
+			return nil, err
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			return nil, err
+		}
+		if n == 0 {
+			return nil, ErrNoRecord
+		}
 	}
-	return int(id), nil
+
+	return s, nil
 }
 
-func (m *SnippetModel) Get(id int) (*Snippet, error) {
-	stmt := `SELECT id, title, content, created, expires FROM snippets
-	WHERE expires > UTC_TIMESTAMP() AND id = ?`
+// Peek is like Get, but never deletes a snippet which burns after reading.
+// It's for its owner, and for looking a snippet up before deciding whether
+// to show it.
+func (m *SnippetModel) Peek(ctx context.Context, slug string) (*Snippet, error) {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
 
-	row := m.DB.QueryRow(stmt, id)
+	stmt := `SELECT s.id, s.slug, COALESCE(s.user_id, 0), COALESCE(u.name, ''), s.title, s.content, s.visibility, s.language, s.format, s.created, s.updated, s.expires, s.burn_after_read
+	FROM snippets s LEFT JOIN users u ON u.id = s.user_id
+	WHERE s.expires > ? AND s.slug = ?`
+
+	return m.get(ctx, stmt, utcNow(), slug)
+}
+
+// GetByID looks a snippet up by its internal numeric ID. It's only needed to
+// redirect links from before snippets had slugs.
+func (m *SnippetModel) GetByID(ctx context.Context, id int) (*Snippet, error) {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	stmt := `SELECT s.id, s.slug, COALESCE(s.user_id, 0), COALESCE(u.name, ''), s.title, s.content, s.visibility, s.language, s.format, s.created, s.updated, s.expires, s.burn_after_read
+	FROM snippets s LEFT JOIN users u ON u.id = s.user_id
+	WHERE s.expires > ? AND s.id = ?`
+
+	return m.get(ctx, stmt, utcNow(), id)
+}
+
+func (m *SnippetModel) get(ctx context.Context, stmt string, args ...any) (*Snippet, error) {
+	row := m.DB.QueryRowContext(ctx, m.Dialect.rebind(stmt), args...)
 
 	s := &Snippet{}
 	// The driver automatically converts the db types to the correct Go types
-	err := row.Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires)
+	err := row.Scan(&s.ID, &s.Slug, &s.UserID, &s.Author, &s.Title, &s.Content, &s.Visibility, &s.Language, &s.Format, &s.Created, &s.Updated, &s.Expires, &s.BurnAfterRead)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, ErrNoRecord
@@ -56,20 +379,28 @@ func (m *SnippetModel) Get(id int) (*Snippet, error) {
 			return nil, err
 		}
 	}
+
+	err = m.loadTags(ctx, []*Snippet{s})
+	if err != nil {
+		return nil, err
+	}
 	return s, nil
 }
 
-func (m *SnippetModel) Latest() ([]*Snippet, error) {
-	// returns 10 latest snippets
+func (m *SnippetModel) Latest(ctx context.Context) ([]*Snippet, error) {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	// returns 10 latest public snippets
 	stmt := `
-		SELECT id, title, content, created, expires 
-		FROM snippets
-		WHERE expires > UTC_TIMESTAMP() 
-		ORDER BY created DESC
+		SELECT s.id, s.slug, COALESCE(s.user_id, 0), COALESCE(u.name, ''), s.title, s.content, s.visibility, s.language, s.format, s.created, s.updated, s.expires, s.burn_after_read
+		FROM snippets s LEFT JOIN users u ON u.id = s.user_id
+		WHERE s.expires > ? AND s.visibility = 'public'
+		ORDER BY s.created DESC
 		LIMIT 10
 	`
 
-	rows, err := m.DB.Query(stmt)
+	rows, err := m.DB.QueryContext(ctx, m.Dialect.rebind(stmt), utcNow())
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, ErrNoRecord
@@ -80,10 +411,413 @@ func (m *SnippetModel) Latest() ([]*Snippet, error) {
 	}
 	defer rows.Close()
 
+	return m.scanSnippets(ctx, rows)
+}
+
+// List returns a page of unexpired public snippets, newest first, skipping
+// the first offset of them.
+func (m *SnippetModel) List(ctx context.Context, limit int, offset int) ([]*Snippet, error) {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	stmt := `
+		SELECT s.id, s.slug, COALESCE(s.user_id, 0), COALESCE(u.name, ''), s.title, s.content, s.visibility, s.language, s.format, s.created, s.updated, s.expires, s.burn_after_read
+		FROM snippets s LEFT JOIN users u ON u.id = s.user_id
+		WHERE s.expires > ? AND s.visibility = 'public'
+		ORDER BY s.created DESC, s.id DESC
+		LIMIT ? OFFSET ?
+	`
+
+	rows, err := m.DB.QueryContext(ctx, m.Dialect.rebind(stmt), utcNow(), limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return m.scanSnippets(ctx, rows)
+}
+
+// SearchPageSize is the number of results on each page returned by Search.
+const SearchPageSize = 10
+
+// Search returns a page of the unexpired public snippets whose title or
+// content match query, best matches first. Pages are numbered from 1, and
+// more reports whether there are further pages. Unlisted and private
+// snippets are never included, as finding them by searching would defeat
+// the point.
+func (m *SnippetModel) Search(ctx context.Context, query string, page int) ([]*Snippet, bool, error) {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	// match filters the snippets and rank orders them, best first
+	match := "MATCH(s.title, s.content) AGAINST(? IN NATURAL LANGUAGE MODE)"
+	rank := "MATCH(s.title, s.content) AGAINST(? IN NATURAL LANGUAGE MODE) DESC,"
+	args := []any{utcNow(), query, query}
+
+	switch m.Dialect {
+	case PostgreSQL:
+		match = "to_tsvector('simple', s.title || ' ' || s.content) @@ plainto_tsquery('simple', ?)"
+		rank = "ts_rank(to_tsvector('simple', s.title || ' ' || s.content), plainto_tsquery('simple', ?)) DESC,"
+	case SQLite:
+		// There's no full-text index, so settle for the snippets which
+		// contain the query, newest first
+		match = `(s.title LIKE ? ESCAPE '\' OR s.content LIKE ? ESCAPE '\')`
+		rank = ""
+		pattern := "%" + likeEscaper.Replace(query) + "%"
+		args = []any{utcNow(), pattern, pattern}
+	}
+
+	stmt := `
+		SELECT s.id, s.slug, COALESCE(s.user_id, 0), COALESCE(u.name, ''), s.title, s.content, s.visibility, s.language, s.format, s.created, s.updated, s.expires, s.burn_after_read
+		FROM snippets s LEFT JOIN users u ON u.id = s.user_id
+		WHERE s.expires > ? AND s.visibility = 'public'
+		AND ` + match + `
+		ORDER BY ` + rank + ` s.created DESC
+		LIMIT ? OFFSET ?
+	`
+
+	// Ask for one more than a page to find out if there's another one
+	args = append(args, SearchPageSize+1, (page-1)*SearchPageSize)
+	rows, err := m.DB.QueryContext(ctx, m.Dialect.rebind(stmt), args...)
+	if err != nil {
+		return nil, false, err
+	}
+	defer rows.Close()
+
+	snippets, err := m.scanSnippets(ctx, rows)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if len(snippets) > SearchPageSize {
+		return snippets[:SearchPageSize], true, nil
+	}
+	return snippets, false, nil
+}
+
+// likeEscaper escapes the characters which are special in a LIKE pattern.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// Browse returns up to limit unexpired public snippets which come after
+// before, newest first.
+func (m *SnippetModel) Browse(ctx context.Context, before Cursor, limit int) ([]*Snippet, error) {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	return m.browse(ctx, "", nil, before, limit)
+}
+
+// Archive is like Browse, but only returns snippets created during the
+// month starting at month.
+func (m *SnippetModel) Archive(ctx context.Context, month time.Time, before Cursor, limit int) ([]*Snippet, error) {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	return m.browse(ctx, "AND s.created >= ? AND s.created < ?", []any{month, month.AddDate(0, 1, 0)}, before, limit)
+}
+
+func (m *SnippetModel) browse(ctx context.Context, where string, args []any, before Cursor, limit int) ([]*Snippet, error) {
+	stmt := `
+		SELECT s.id, s.slug, COALESCE(s.user_id, 0), COALESCE(u.name, ''), s.title, s.content, s.visibility, s.language, s.format, s.created, s.updated, s.expires, s.burn_after_read
+		FROM snippets s LEFT JOIN users u ON u.id = s.user_id
+		WHERE s.expires > ? AND s.visibility = 'public' ` + where
+	args = append([]any{utcNow()}, args...)
+
+	// Comparing created and id separately, rather than as a row, lets MySQL
+	// use idx_snippets_created, which also holds the id, to skip straight
+	// to the cursor
+	if !before.IsZero() {
+		stmt += " AND (s.created < ? OR (s.created = ? AND s.id < ?))"
+		args = append(args, before.Created, before.Created, before.ID)
+	}
+	stmt += " ORDER BY s.created DESC, s.id DESC LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := m.DB.QueryContext(ctx, m.Dialect.rebind(stmt), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return m.scanSnippets(ctx, rows)
+}
+
+// ArchiveMonths returns the months with unexpired public snippets and how
+// many there are in each, newest first.
+func (m *SnippetModel) ArchiveMonths(ctx context.Context) ([]*ArchiveMonth, error) {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	month := "DATE_FORMAT(created, '%Y-%m')"
+	switch m.Dialect {
+	case SQLite:
+		month = "strftime('%Y-%m', created)"
+	case PostgreSQL:
+		month = "to_char(created, 'YYYY-MM')"
+	}
+
+	stmt := `
+		SELECT ` + month + ` AS month, COUNT(*) FROM snippets
+		WHERE expires > ? AND visibility = 'public'
+		GROUP BY month
+		ORDER BY month DESC
+	`
+
+	rows, err := m.DB.QueryContext(ctx, m.Dialect.rebind(stmt), utcNow())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	months := []*ArchiveMonth{}
+	for rows.Next() {
+		var month string
+		a := &ArchiveMonth{}
+		err := rows.Scan(&month, &a.Count)
+		if err != nil {
+			return nil, err
+		}
+		a.Month, err = time.Parse("2006-01", month)
+		if err != nil {
+			return nil, err
+		}
+		months = append(months, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return months, nil
+}
+
+// ByTag is like Browse, but only returns snippets with the given tag.
+func (m *SnippetModel) ByTag(ctx context.Context, tag string, before Cursor, limit int) ([]*Snippet, error) {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	where := `AND s.id IN (
+		SELECT st.snippet_id FROM snippet_tags st INNER JOIN tags t ON t.id = st.tag_id
+		WHERE t.name = ?
+	)`
+	return m.browse(ctx, where, []any{tag}, before, limit)
+}
+
+// TagCloud returns up to limit of the tags used most by unexpired public
+// snippets, in alphabetical order.
+func (m *SnippetModel) TagCloud(ctx context.Context, limit int) ([]*TagCount, error) {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	stmt := `
+		SELECT name, count FROM (
+			SELECT t.name, COUNT(*) AS count
+			FROM tags t
+			INNER JOIN snippet_tags st ON st.tag_id = t.id
+			INNER JOIN snippets s ON s.id = st.snippet_id
+			WHERE s.expires > ? AND s.visibility = 'public'
+			GROUP BY t.name
+			ORDER BY count DESC, t.name
+			LIMIT ?
+		) top
+		ORDER BY name
+	`
+
+	rows, err := m.DB.QueryContext(ctx, m.Dialect.rebind(stmt), utcNow(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []*TagCount{}
+	for rows.Next() {
+		t := &TagCount{}
+		err := rows.Scan(&t.Name, &t.Count)
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+// Update replaces the title and content of an existing snippet, sets its new
+// expiry and records the new version as the next revision.
+func (m *SnippetModel) Update(ctx context.Context, id int, userID int, title string, content string, visibility string, language string, format string, tags []string, expires time.Time, burnAfterRead bool) error {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Lock the snippet row so concurrent edits can't both claim the same
+	// revision number. SQLite has no row locks, but it only has the one
+	// connection so transactions can't overlap anyway.
+	lock := " FOR UPDATE"
+	if m.Dialect == SQLite {
+		lock = ""
+	}
+	var exists int
+	err = tx.QueryRowContext(ctx, m.Dialect.rebind("SELECT id FROM snippets WHERE id = ?"+lock), id).Scan(&exists)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNoRecord
+		}
+		return err
+	}
+
+	// Snippets created before revisions were tracked have no history yet, so
+	// keep their original version as revision 1 before it's overwritten
+	stmt := `INSERT INTO snippet_revisions (snippet_id, revision, user_id, title, content, created)
+	SELECT id, 1, user_id, title, content, created FROM snippets
+	WHERE id = ? AND NOT EXISTS (SELECT true FROM snippet_revisions WHERE snippet_id = ?)`
+	_, err = tx.ExecContext(ctx, m.Dialect.rebind(stmt), id, id)
+	if err != nil {
+		return err
+	}
+
+	now := utcNow()
+	stmt = `UPDATE snippets
+	SET title = ?, content = ?, visibility = ?, language = ?, format = ?, updated = ?, expires = ?, burn_after_read = ?
+	WHERE id = ?`
+	_, err = tx.ExecContext(ctx, m.Dialect.rebind(stmt), title, content, visibility, language, format, now, expires.UTC().Truncate(time.Second), burnAfterRead, id)
+	if err != nil {
+		return err
+	}
+
+	var revision int
+	err = tx.QueryRowContext(ctx, m.Dialect.rebind("SELECT MAX(revision) + 1 FROM snippet_revisions WHERE snippet_id = ?"), id).Scan(&revision)
+	if err != nil {
+		return err
+	}
+
+	stmt = `INSERT INTO snippet_revisions (snippet_id, revision, user_id, title, content, created)
+	VALUES(?, ?, ?, ?, ?, ?)`
+	_, err = tx.ExecContext(ctx, m.Dialect.rebind(stmt), id, revision, userID, title, content, now)
+	if err != nil {
+		return err
+	}
+
+	err = m.setTags(ctx, tx, id, tags)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (m *SnippetModel) Delete(ctx context.Context, id int) error {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	stmt := "DELETE FROM snippets WHERE id = ?"
+
+	result, err := m.DB.ExecContext(ctx, m.Dialect.rebind(stmt), id)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNoRecord
+	}
+	return nil
+}
+
+// DeleteExpired deletes up to limit snippets which have expired, along with
+// their revisions and tags, and returns how many it deleted. Deleting in
+// batches keeps each statement from holding locks for long.
+func (m *SnippetModel) DeleteExpired(ctx context.Context, limit int) (int, error) {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	stmt := "DELETE FROM snippets WHERE expires <= ? ORDER BY expires LIMIT ?"
+	if m.Dialect != MySQL {
+		// The others can't limit a DELETE, but unlike MySQL they can limit a
+		// subquery
+		stmt = "DELETE FROM snippets WHERE id IN (SELECT id FROM snippets WHERE expires <= ? ORDER BY expires LIMIT ?)"
+	}
+
+	result, err := m.DB.ExecContext(ctx, m.Dialect.rebind(stmt), utcNow(), limit)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return int(n), nil
+}
+
+// ByUser returns the unexpired snippets created by the given user, whatever
+// their visibility, newest first.
+func (m *SnippetModel) ByUser(ctx context.Context, userID int) ([]*Snippet, error) {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	stmt := `
+		SELECT s.id, s.slug, COALESCE(s.user_id, 0), COALESCE(u.name, ''), s.title, s.content, s.visibility, s.language, s.format, s.created, s.updated, s.expires, s.burn_after_read
+		FROM snippets s LEFT JOIN users u ON u.id = s.user_id
+		WHERE s.expires > ? AND s.user_id = ?
+		ORDER BY s.created DESC
+	`
+
+	rows, err := m.DB.QueryContext(ctx, m.Dialect.rebind(stmt), utcNow(), userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return m.scanSnippets(ctx, rows)
+}
+
+// Revisions returns every saved version of a snippet, newest first.
+func (m *SnippetModel) Revisions(ctx context.Context, snippetID int) ([]*SnippetRevision, error) {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	stmt := `
+		SELECT r.id, r.snippet_id, r.revision, r.user_id, u.name, r.title, r.content, r.created
+		FROM snippet_revisions r INNER JOIN users u ON u.id = r.user_id
+		WHERE r.snippet_id = ?
+		ORDER BY r.revision DESC
+	`
+
+	rows, err := m.DB.QueryContext(ctx, m.Dialect.rebind(stmt), snippetID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []*SnippetRevision{}
+	for rows.Next() {
+		r := &SnippetRevision{}
+		err := rows.Scan(&r.ID, &r.SnippetID, &r.Revision, &r.UserID, &r.Author, &r.Title, &r.Content, &r.Created)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return revisions, nil
+}
+
+// scanSnippets reads the snippets from rows and then looks up their tags.
+func (m *SnippetModel) scanSnippets(ctx context.Context, rows *sql.Rows) ([]*Snippet, error) {
 	snippets := []*Snippet{}
 	for rows.Next() {
 		s := &Snippet{}
-		err := rows.Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires)
+		err := rows.Scan(&s.ID, &s.Slug, &s.UserID, &s.Author, &s.Title, &s.Content, &s.Visibility, &s.Language, &s.Format, &s.Created, &s.Updated, &s.Expires, &s.BurnAfterRead)
 		if err != nil {
 			return nil, err
 		}
@@ -93,8 +827,53 @@ func (m *SnippetModel) Latest() ([]*Snippet, error) {
 	// error that was encountered during the iteration. It's important to
 	// call this - don't assume that a successful iteration was completed
 	// over the whole resultset.
-	if err = rows.Err(); err != nil {
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	err := m.loadTags(ctx, snippets)
+	if err != nil {
 		return nil, err
 	}
 	return snippets, nil
 }
+
+// loadTags fills in the tags of each snippet with a single query.
+func (m *SnippetModel) loadTags(ctx context.Context, snippets []*Snippet) error {
+	if len(snippets) == 0 {
+		return nil
+	}
+
+	bySnippet := make(map[int]*Snippet, len(snippets))
+	args := make([]any, len(snippets))
+	for i, s := range snippets {
+		s.Tags = []string{}
+		bySnippet[s.ID] = s
+		args[i] = s.ID
+	}
+
+	stmt := `
+		SELECT st.snippet_id, t.name
+		FROM snippet_tags st INNER JOIN tags t ON t.id = st.tag_id
+		WHERE st.snippet_id IN (?` + strings.Repeat(", ?", len(snippets)-1) + `)
+		ORDER BY t.name
+	`
+
+	rows, err := m.DB.QueryContext(ctx, m.Dialect.rebind(stmt), args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int
+		var name string
+		err := rows.Scan(&id, &name)
+		if err != nil {
+			return err
+		}
+		s := bySnippet[id]
+		s.Tags = append(s.Tags, name)
+	}
+	return rows.Err()
+}
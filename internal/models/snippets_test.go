@@ -0,0 +1,401 @@
+package models
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"snippetbox.cozycole.net/internal/assert"
+)
+
+func TestSnippetModelByUser(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	tests := []struct {
+		name   string
+		userID int
+		want   int
+	}{
+		{
+			name:   "Owner",
+			userID: 1,
+			want:   1,
+		},
+		{
+			name:   "Non-existent user",
+			userID: 2,
+			want:   0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, dialect := newTestDB(t)
+
+			m := SnippetModel{DB: db, Dialect: dialect}
+
+			snippets, err := m.ByUser(context.Background(), tt.userID)
+
+			assert.NilError(t, err)
+			assert.Equal(t, len(snippets), tt.want)
+		})
+	}
+}
+
+func TestSnippetModelList(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	tests := []struct {
+		name   string
+		limit  int
+		offset int
+		want   int
+	}{
+		{
+			name:   "First page",
+			limit:  20,
+			offset: 0,
+			want:   1,
+		},
+		{
+			name:   "Past the end",
+			limit:  20,
+			offset: 20,
+			want:   0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, dialect := newTestDB(t)
+
+			m := SnippetModel{DB: db, Dialect: dialect}
+
+			snippets, err := m.List(context.Background(), tt.limit, tt.offset)
+
+			assert.NilError(t, err)
+			assert.Equal(t, len(snippets), tt.want)
+		})
+	}
+}
+
+func TestSnippetModelSearch(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	tests := []struct {
+		name  string
+		query string
+		page  int
+		want  int
+	}{
+		{
+			name:  "Match",
+			query: "pond",
+			page:  1,
+			want:  1,
+		},
+		{
+			name:  "No match",
+			query: "frog",
+			page:  1,
+			want:  0,
+		},
+		{
+			name:  "Past the last page",
+			query: "pond",
+			page:  2,
+			want:  0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, dialect := newTestDB(t)
+
+			m := SnippetModel{DB: db, Dialect: dialect}
+
+			snippets, more, err := m.Search(context.Background(), tt.query, tt.page)
+
+			assert.NilError(t, err)
+			assert.Equal(t, len(snippets), tt.want)
+			assert.Equal(t, more, false)
+		})
+	}
+}
+
+func TestSnippetModelBrowse(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	tests := []struct {
+		name   string
+		before Cursor
+		want   int
+	}{
+		{
+			name: "First page",
+			want: 1,
+		},
+		{
+			name:   "After the fixture",
+			before: Cursor{Created: time.Date(2022, 1, 1, 10, 0, 0, 0, time.UTC), ID: 1},
+			want:   0,
+		},
+		{
+			name:   "Same time, later ID",
+			before: Cursor{Created: time.Date(2022, 1, 1, 10, 0, 0, 0, time.UTC), ID: 2},
+			want:   1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, dialect := newTestDB(t)
+
+			m := SnippetModel{DB: db, Dialect: dialect}
+
+			snippets, err := m.Browse(context.Background(), tt.before, 10)
+
+			assert.NilError(t, err)
+			assert.Equal(t, len(snippets), tt.want)
+		})
+	}
+}
+
+func TestSnippetModelArchive(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db, dialect := newTestDB(t)
+
+	m := SnippetModel{DB: db, Dialect: dialect}
+
+	months, err := m.ArchiveMonths(context.Background())
+	assert.NilError(t, err)
+	assert.Equal(t, len(months), 1)
+	assert.Equal(t, months[0].Month, time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC))
+	assert.Equal(t, months[0].Count, 1)
+
+	snippets, err := m.Archive(context.Background(), months[0].Month, Cursor{}, 10)
+	assert.NilError(t, err)
+	assert.Equal(t, len(snippets), 1)
+
+	snippets, err = m.Archive(context.Background(), time.Date(2022, 2, 1, 0, 0, 0, 0, time.UTC), Cursor{}, 10)
+	assert.NilError(t, err)
+	assert.Equal(t, len(snippets), 0)
+}
+
+func TestSnippetModelUpdate(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db, dialect := newTestDB(t)
+
+	m := SnippetModel{DB: db, Dialect: dialect}
+
+	snippet, err := m.Get(context.Background(), "pond4Xk9Qa")
+	assert.NilError(t, err)
+
+	edits := []struct {
+		title   string
+		content string
+	}{
+		{"An old still pond", "An old still pond..."},
+		{"A frog jumps in", "A frog jumps into the pond, splash! Silence again."},
+	}
+	for _, e := range edits {
+		err = m.Update(context.Background(), snippet.ID, 1, e.title, e.content, snippet.Visibility, snippet.Language, snippet.Format, snippet.Tags, snippet.Expires, false)
+		assert.NilError(t, err)
+	}
+
+	updated, err := m.Get(context.Background(), "pond4Xk9Qa")
+	assert.NilError(t, err)
+	assert.Equal(t, updated.Title, "A frog jumps in")
+	assert.Equal(t, updated.Content, "A frog jumps into the pond, splash! Silence again.")
+
+	// Newest first, starting from the snippet as it was created
+	revisions, err := m.Revisions(context.Background(), snippet.ID)
+	assert.NilError(t, err)
+	assert.Equal(t, len(revisions), 3)
+
+	want := []struct {
+		title   string
+		content string
+	}{
+		{snippet.Title, snippet.Content},
+		edits[0],
+		edits[1],
+	}
+	for i, w := range want {
+		revision := revisions[len(revisions)-1-i]
+		assert.Equal(t, revision.Revision, i+1)
+		assert.Equal(t, revision.SnippetID, snippet.ID)
+		assert.Equal(t, revision.Author, "Alice Jones")
+		assert.Equal(t, revision.Title, w.title)
+		assert.Equal(t, revision.Content, w.content)
+	}
+}
+
+func TestSnippetModelTags(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db, dialect := newTestDB(t)
+
+	m := SnippetModel{DB: db, Dialect: dialect}
+
+	snippet, err := m.Get(context.Background(), "pond4Xk9Qa")
+	assert.NilError(t, err)
+	assert.Equal(t, strings.Join(snippet.Tags, ","), "haiku")
+
+	snippets, err := m.ByTag(context.Background(), "haiku", Cursor{}, 10)
+	assert.NilError(t, err)
+	assert.Equal(t, len(snippets), 1)
+
+	// Reusing an existing tag and adding a new one
+	err = m.Update(context.Background(), snippet.ID, 1, snippet.Title, snippet.Content, snippet.Visibility, snippet.Language, snippet.Format, []string{"haiku", "nature"}, snippet.Expires, false)
+	assert.NilError(t, err)
+
+	cloud, err := m.TagCloud(context.Background(), 10)
+	assert.NilError(t, err)
+	assert.Equal(t, len(cloud), 2)
+	assert.Equal(t, cloud[0].Name, "haiku")
+	assert.Equal(t, cloud[1].Name, "nature")
+	assert.Equal(t, cloud[1].Count, 1)
+
+	snippets, err = m.ByTag(context.Background(), "nature", Cursor{}, 10)
+	assert.NilError(t, err)
+	assert.Equal(t, len(snippets), 1)
+	assert.Equal(t, strings.Join(snippets[0].Tags, ","), "haiku,nature")
+
+	snippets, err = m.ByTag(context.Background(), "frog", Cursor{}, 10)
+	assert.NilError(t, err)
+	assert.Equal(t, len(snippets), 0)
+}
+
+func TestSnippetModelBurnAfterRead(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db, dialect := newTestDB(t)
+
+	m := SnippetModel{DB: db, Dialect: dialect}
+
+	slug, err := m.Insert(context.Background(), 1, "Secret", "hunter2", VisibilityUnlisted, "plaintext", FormatPlain, nil, time.Now().Add(time.Hour), true)
+	assert.NilError(t, err)
+
+	// The owner can look at it as often as they like
+	for i := 0; i < 2; i++ {
+		snippet, err := m.Peek(context.Background(), slug)
+		assert.NilError(t, err)
+		assert.Equal(t, snippet.BurnAfterRead, true)
+	}
+
+	snippet, err := m.Get(context.Background(), slug)
+	assert.NilError(t, err)
+	assert.Equal(t, snippet.Content, "hunter2")
+
+	_, err = m.Get(context.Background(), slug)
+	assert.Equal(t, err, ErrNoRecord)
+}
+
+func TestSnippetModelDeleteExpired(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db, dialect := newTestDB(t)
+
+	m := SnippetModel{DB: db, Dialect: dialect}
+
+	for i := 0; i < 3; i++ {
+		_, err := m.Insert(context.Background(), 1, "Old news", "Yesterday's news", VisibilityPublic, "plaintext", FormatPlain, []string{"news"}, time.Now().Add(-time.Hour), false)
+		assert.NilError(t, err)
+	}
+
+	n, err := m.DeleteExpired(context.Background(), 2)
+	assert.NilError(t, err)
+	assert.Equal(t, n, 2)
+
+	n, err = m.DeleteExpired(context.Background(), 2)
+	assert.NilError(t, err)
+	assert.Equal(t, n, 1)
+
+	// The fixture snippet hasn't expired so it's still there
+	_, err = m.Get(context.Background(), "pond4Xk9Qa")
+	assert.NilError(t, err)
+}
+
+func TestParseCursor(t *testing.T) {
+	c := Cursor{Created: time.Date(2022, 1, 1, 10, 0, 0, 0, time.UTC), ID: 42}
+
+	got, err := ParseCursor(c.String())
+	assert.NilError(t, err)
+	assert.Equal(t, got, c)
+
+	for _, s := range []string{"", "1641031200", "1641031200-0", "x-1", "1641031200-y"} {
+		_, err := ParseCursor(s)
+		assert.Equal(t, err, ErrInvalidCursor)
+	}
+}
+
+func TestNewSlug(t *testing.T) {
+	seen := map[string]bool{}
+
+	for i := 0; i < 1000; i++ {
+		slug, err := newSlug()
+
+		assert.NilError(t, err)
+		assert.Equal(t, ValidSlug(slug), true)
+		assert.Equal(t, seen[slug], false)
+
+		seen[slug] = true
+	}
+}
+
+func TestValidSlug(t *testing.T) {
+	tests := []struct {
+		name string
+		slug string
+		want bool
+	}{
+		{
+			name: "Valid",
+			slug: "pond4Xk9Qa",
+			want: true,
+		},
+		{
+			name: "Too short",
+			slug: "pond4Xk9Q",
+			want: false,
+		},
+		{
+			name: "Bad character",
+			slug: "pond4Xk9Q-",
+			want: false,
+		},
+		{
+			name: "All digits",
+			slug: "1234567890",
+			want: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, ValidSlug(tt.slug), tt.want)
+		})
+	}
+}
@@ -14,9 +14,15 @@ CREATE TABLE users (
     created DATETIME NOT NULL
 );
 ALTER TABLE users ADD CONSTRAINT users_uc_email UNIQUE (email);
-INSERT INTO users (name, email, hashed_password, created) VALUES (
-    'Alice Jones',
-    'alice@example.com',
-    '$2a$12$NuTjWXm3KKntReFwyBVHyuf/to.HEwTy.eS206TNfkGfr6HzGJSWG',
-    '2022-01-01 10:00:00'
+CREATE TABLE sessions (
+    token CHAR(43) PRIMARY KEY,
+    data BLOB NOT NULL,
+    expiry TIMESTAMP(6) NOT NULL
+);
+CREATE INDEX sessions_expiry_idx ON sessions (expiry);
+INSERT INTO snippets (title, content, created, expires) VALUES (
+    'An old silent pond',
+    'An old silent pond...',
+    '2022-01-01 10:00:00',
+    '2099-01-01 10:00:00'
 );
\ No newline at end of file
@@ -0,0 +1,28 @@
+CREATE TABLE snippets (
+    id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    title VARCHAR(100) NOT NULL,
+    content TEXT NOT NULL,
+    created TIMESTAMP NOT NULL,
+    expires TIMESTAMP NOT NULL
+);
+CREATE INDEX idx_snippets_created ON snippets(created);
+CREATE TABLE users (
+    id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    email VARCHAR(255) NOT NULL,
+    hashed_password CHAR(60) NOT NULL,
+    created TIMESTAMP NOT NULL,
+    CONSTRAINT users_uc_email UNIQUE (email)
+);
+CREATE TABLE sessions (
+    token TEXT PRIMARY KEY,
+    data BYTEA NOT NULL,
+    expiry TIMESTAMP(6) NOT NULL
+);
+CREATE INDEX sessions_expiry_idx ON sessions (expiry);
+INSERT INTO snippets (title, content, created, expires) VALUES (
+    'An old silent pond',
+    'An old silent pond...',
+    '2022-01-01 10:00:00',
+    '2099-01-01 10:00:00'
+);
\ No newline at end of file
@@ -0,0 +1,25 @@
+INSERT INTO users (name, email, hashed_password, created) VALUES (
+    'Alice Jones',
+    'alice@example.com',
+    '$2a$12$NuTjWXm3KKntReFwyBVHyuf/to.HEwTy.eS206TNfkGfr6HzGJSWG',
+    '2022-01-01 10:00:00'
+);
+INSERT INTO snippets (slug, user_id, title, content, created, updated, expires) VALUES (
+    'pond4Xk9Qa',
+    1,
+    'An old silent pond',
+    'An old silent pond...',
+    '2022-01-01 10:00:00',
+    '2022-01-01 10:00:00',
+    '2099-01-01 10:00:00'
+);
+INSERT INTO snippet_revisions (snippet_id, revision, user_id, title, content, created) VALUES (
+    1,
+    1,
+    1,
+    'An old silent pond',
+    'An old silent pond...',
+    '2022-01-01 10:00:00'
+);
+INSERT INTO tags (name) VALUES ('haiku');
+INSERT INTO snippet_tags (snippet_id, tag_id) VALUES (1, 1);
\ No newline at end of file
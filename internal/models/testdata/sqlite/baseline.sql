@@ -0,0 +1,28 @@
+CREATE TABLE snippets (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    title TEXT NOT NULL,
+    content TEXT NOT NULL,
+    created TIMESTAMP NOT NULL,
+    expires TIMESTAMP NOT NULL
+);
+CREATE INDEX idx_snippets_created ON snippets(created);
+CREATE TABLE users (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL,
+    email TEXT NOT NULL,
+    hashed_password TEXT NOT NULL,
+    created TIMESTAMP NOT NULL,
+    CONSTRAINT users_uc_email UNIQUE (email)
+);
+CREATE TABLE sessions (
+    token TEXT PRIMARY KEY,
+    data BLOB NOT NULL,
+    expiry TIMESTAMP NOT NULL
+);
+CREATE INDEX sessions_expiry_idx ON sessions (expiry);
+INSERT INTO snippets (title, content, created, expires) VALUES (
+    'An old silent pond',
+    'An old silent pond...',
+    '2022-01-01 10:00:00+00:00',
+    '2099-01-01 10:00:00+00:00'
+);
\ No newline at end of file
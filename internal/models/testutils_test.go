@@ -3,36 +3,60 @@ package models
 import (
 	"database/sql"
 	"os"
+	"path/filepath"
 	"testing"
 )
 
-func newTestDB(t *testing.T) *sql.DB {
-	db, err := sql.Open("mysql", "test_web:pass@/test_snippetbox?parseTime=true&multiStatements=true")
-	if err != nil {
-		t.Fatal(err)
+// testDSN is the database the integration tests run against. It's MySQL
+// unless SNIPPETBOX_TEST_DSN says otherwise, such as sqlite::memory: to run
+// them without a database server.
+func testDSN() string {
+	if dsn := os.Getenv("SNIPPETBOX_TEST_DSN"); dsn != "" {
+		return dsn
 	}
+	return "test_web:pass@/test_snippetbox?parseTime=true&multiStatements=true"
+}
 
-	script, err := os.ReadFile("./testdata/setup.sql")
+func newTestDB(t *testing.T) (*sql.DB, Dialect) {
+	db, dialect, migrator := openTestDB(t)
+
+	// The schema is built by the same migrations as in production, then
+	// the fixtures for the dialect are added
+	_, err := migrator.Up()
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	_, err = db.Exec(string(script))
+	execTestdata(t, db, dialect, "setup.sql")
+
+	return db, dialect
+}
+
+// openTestDB opens the test database without building the schema, and
+// returns a Migrator for it.
+func openTestDB(t *testing.T) (*sql.DB, Dialect, *Migrator) {
+	db, dialect, err := Open(testDSN())
 	if err != nil {
 		t.Fatal(err)
 	}
+	migrator := &Migrator{DB: db, Dialect: dialect}
 
 	// Use the t.Cleanup() to register a function *which will automatically be
 	// called by Go when the current test (or sub-test) which calls newTestDB()
-	// has finished*. In this function we read and execute the teardown script,
-	// and close the database connection pool.
+	// has finished*. In this function we revert every migration, drop the
+	// table which tracks them, and close the database connection pool.
 	t.Cleanup(func() {
-		script, err := os.ReadFile("./testdata/teardown.sql")
-		if err != nil {
-			t.Fatal(err)
+		for {
+			migration, err := migrator.Down()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if migration == nil {
+				break
+			}
 		}
 
-		_, err = db.Exec(string(script))
+		_, err = db.Exec("DROP TABLE schema_migrations")
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -40,5 +64,18 @@ func newTestDB(t *testing.T) *sql.DB {
 		db.Close()
 	})
 
-	return db
+	return db, dialect, migrator
+}
+
+// execTestdata runs the named script from the dialect's testdata directory.
+func execTestdata(t *testing.T, db *sql.DB, dialect Dialect, name string) {
+	script, err := os.ReadFile(filepath.Join("testdata", dialect.String(), name))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = db.Exec(string(script))
+	if err != nil {
+		t.Fatal(err)
+	}
 }
@@ -0,0 +1,31 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// withTimeout returns a context which is cancelled after d, or when ctx is,
+// for a model method to run its queries under. A d of zero leaves ctx's own
+// deadline, if it has one, as the only limit.
+func withTimeout(ctx context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	if d <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, d)
+}
+
+// IsTimeout reports whether err is from a query which ran out of time. The
+// MySQL and SQLite drivers give back the context's error, while PostgreSQL
+// cancels the query on the server, which reports query_canceled.
+func IsTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+
+	var pqError *pq.Error
+	return errors.As(err, &pqError) && pqError.Code == "57014"
+}
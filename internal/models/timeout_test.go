@@ -0,0 +1,68 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"snippetbox.cozycole.net/internal/assert"
+
+	"github.com/lib/pq"
+)
+
+func TestIsTimeout(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{
+			name: "Deadline exceeded",
+			err:  fmt.Errorf("query: %w", context.DeadlineExceeded),
+			want: true,
+		},
+		{
+			name: "PostgreSQL query canceled",
+			err:  &pq.Error{Code: "57014"},
+			want: true,
+		},
+		{
+			name: "Canceled",
+			err:  context.Canceled,
+			want: false,
+		},
+		{
+			name: "Other error",
+			err:  errors.New("connection refused"),
+			want: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, IsTimeout(tt.err), tt.want)
+		})
+	}
+}
+
+func TestModelTimeout(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db, dialect := newTestDB(t)
+
+	m := SnippetModel{DB: db, Dialect: dialect, Timeout: time.Nanosecond}
+
+	_, err := m.Latest(context.Background())
+	assert.Equal(t, IsTimeout(err), true)
+
+	m.Timeout = 0
+	ctx, cancel := context.WithTimeout(context.Background(), -time.Second)
+	defer cancel()
+
+	_, err = m.Latest(ctx)
+	assert.Equal(t, IsTimeout(err), true)
+}
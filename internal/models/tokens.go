@@ -0,0 +1,180 @@
+package models
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+)
+
+// Token scopes. A read token can see its owner's private snippets and a
+// write token can create, change and delete their snippets.
+const (
+	ScopeRead  = "snippets:read"
+	ScopeWrite = "snippets:write"
+)
+
+// tokenPrefix marks personal access tokens so they're easy to recognise, for
+// example by secret scanners.
+const tokenPrefix = "sbx_"
+
+// A Token is a personal access token, which lets scripts authenticate as a
+// user. Only a hash of the token itself is stored, so it can't be shown
+// again after it's created.
+type Token struct {
+	ID       int
+	UserID   int
+	Name     string
+	Scopes   []string
+	Created  time.Time
+	LastUsed sql.NullTime
+}
+
+// HasScope reports whether the token was granted scope.
+func (t *Token) HasScope(scope string) bool {
+	for _, s := range t.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+type TokenModelInterface interface {
+	Insert(ctx context.Context, userID int, name string, scopes []string) (string, error)
+	Authenticate(ctx context.Context, plaintext string) (*Token, error)
+	ByUser(ctx context.Context, userID int) ([]*Token, error)
+	Delete(ctx context.Context, id int, userID int) error
+}
+
+type TokenModel struct {
+	DB      *sql.DB
+	Dialect Dialect
+	// Timeout limits how long each call may spend in the database. Zero
+	// means no limit beyond the caller's context.
+	Timeout time.Duration
+}
+
+func hashToken(plaintext string) string {
+	sum := sha256.Sum256([]byte(plaintext))
+	return hex.EncodeToString(sum[:])
+}
+
+// Insert creates a new token for the user and returns its plaintext, which
+// must be shown to them now as it can't be recovered later.
+func (m *TokenModel) Insert(ctx context.Context, userID int, name string, scopes []string) (string, error) {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	plaintext := tokenPrefix + base64.RawURLEncoding.EncodeToString(b)
+
+	stmt := `INSERT INTO tokens (user_id, name, hash, scopes, created)
+	VALUES(?, ?, ?, ?, ?)`
+
+	_, err = m.DB.ExecContext(ctx, m.Dialect.rebind(stmt), userID, name, hashToken(plaintext), strings.Join(scopes, " "), utcNow())
+	if err != nil {
+		return "", err
+	}
+
+	return plaintext, nil
+}
+
+// Authenticate looks up the token with the given plaintext and records that
+// it has been used. It returns ErrInvalidCredentials if there's no such
+// token.
+func (m *TokenModel) Authenticate(ctx context.Context, plaintext string) (*Token, error) {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	if !strings.HasPrefix(plaintext, tokenPrefix) {
+		return nil, ErrInvalidCredentials
+	}
+	hash := hashToken(plaintext)
+
+	stmt := "SELECT id, user_id, name, scopes, created, last_used FROM tokens WHERE hash = ?"
+
+	t := &Token{}
+	var scopes string
+	err := m.DB.QueryRowContext(ctx, m.Dialect.rebind(stmt), hash).Scan(&t.ID, &t.UserID, &t.Name, &scopes, &t.Created, &t.LastUsed)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrInvalidCredentials
+		}
+		return nil, err
+	}
+	t.Scopes = strings.Fields(scopes)
+
+	_, err = m.DB.ExecContext(ctx, m.Dialect.rebind("UPDATE tokens SET last_used = ? WHERE id = ?"), utcNow(), t.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// ByUser returns the user's tokens, newest first.
+func (m *TokenModel) ByUser(ctx context.Context, userID int) ([]*Token, error) {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	stmt := `
+		SELECT id, user_id, name, scopes, created, last_used FROM tokens
+		WHERE user_id = ?
+		ORDER BY created DESC, id DESC
+	`
+
+	rows, err := m.DB.QueryContext(ctx, m.Dialect.rebind(stmt), userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := []*Token{}
+	for rows.Next() {
+		t := &Token{}
+		var scopes string
+		err := rows.Scan(&t.ID, &t.UserID, &t.Name, &scopes, &t.Created, &t.LastUsed)
+		if err != nil {
+			return nil, err
+		}
+		t.Scopes = strings.Fields(scopes)
+		tokens = append(tokens, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// Delete revokes one of the user's tokens. Tokens belonging to anyone else
+// are treated as not existing.
+func (m *TokenModel) Delete(ctx context.Context, id int, userID int) error {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	stmt := "DELETE FROM tokens WHERE id = ? AND user_id = ?"
+
+	result, err := m.DB.ExecContext(ctx, m.Dialect.rebind(stmt), id, userID)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNoRecord
+	}
+	return nil
+}
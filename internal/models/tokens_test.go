@@ -0,0 +1,49 @@
+package models
+
+import (
+	"context"
+	"testing"
+
+	"snippetbox.cozycole.net/internal/assert"
+)
+
+func TestTokenModel(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db, dialect := newTestDB(t)
+
+	m := TokenModel{DB: db, Dialect: dialect}
+
+	plaintext, err := m.Insert(context.Background(), 1, "laptop", []string{ScopeRead})
+	assert.NilError(t, err)
+
+	t.Run("Valid token", func(t *testing.T) {
+		token, err := m.Authenticate(context.Background(), plaintext)
+
+		assert.NilError(t, err)
+		assert.Equal(t, token.UserID, 1)
+		assert.Equal(t, token.HasScope(ScopeRead), true)
+		assert.Equal(t, token.HasScope(ScopeWrite), false)
+	})
+
+	t.Run("Wrong token", func(t *testing.T) {
+		_, err := m.Authenticate(context.Background(), plaintext+"x")
+
+		assert.Equal(t, err, ErrInvalidCredentials)
+	})
+
+	t.Run("Revoked token", func(t *testing.T) {
+		tokens, err := m.ByUser(context.Background(), 1)
+		assert.NilError(t, err)
+		assert.Equal(t, len(tokens), 1)
+		assert.Equal(t, tokens[0].LastUsed.Valid, true)
+
+		assert.Equal(t, m.Delete(context.Background(), tokens[0].ID, 2), ErrNoRecord)
+		assert.NilError(t, m.Delete(context.Background(), tokens[0].ID, 1))
+
+		_, err = m.Authenticate(context.Background(), plaintext)
+		assert.Equal(t, err, ErrInvalidCredentials)
+	})
+}
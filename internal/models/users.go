@@ -1,21 +1,21 @@
 package models
 
 import (
+	"context"
 	"database/sql"
 	"errors"
-	"strings"
 	"time"
 
-	"github.com/go-sql-driver/mysql"
 	"golang.org/x/crypto/bcrypt"
 )
 
 type UserModelInterface interface {
-	Insert(name, email, password string) error
-	Authenticate(email, password string) (int, error)
-	Exists(id int) (bool, error)
-	Get(id int) (*User, error)
-	UpdatePassword(id int, password string) error
+	Insert(ctx context.Context, name, email, password string) (int, error)
+	Authenticate(ctx context.Context, email, password string) (int, error)
+	Exists(ctx context.Context, id int) (bool, error)
+	Get(ctx context.Context, id int) (*User, error)
+	UpdatePassword(ctx context.Context, id int, password string) error
+	Verify(ctx context.Context, id int) error
 }
 
 type User struct {
@@ -24,44 +24,55 @@ type User struct {
 	Email          string
 	HashedPassword []byte
 	Created        time.Time
+	// Verified is whether the user has followed the link emailed to them
+	// at signup. Accounts from before verification was added count as
+	// verified.
+	Verified bool
 }
 
 type UserModel struct {
-	DB *sql.DB
+	DB      *sql.DB
+	Dialect Dialect
+	// Timeout limits how long each call may spend in the database. Zero
+	// means no limit beyond the caller's context.
+	Timeout time.Duration
 }
 
-func (m *UserModel) Insert(name, email, password string) error {
+// Insert adds a new, unverified user and returns its ID.
+func (m *UserModel) Insert(ctx context.Context, name, email, password string) (int, error) {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
 
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), 12)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
 	stmt := `INSERT INTO users (name, email, hashed_password, created)
-	VALUES(?, ?, ?, UTC_TIMESTAMP())`
+	VALUES(?, ?, ?, ?)`
 
-	_, err = m.DB.Exec(stmt, name, email, string(hashedPassword))
+	id, err := m.Dialect.insertID(ctx, m.DB, stmt, name, email, string(hashedPassword), utcNow())
 
 	if err != nil {
-		var mySQLError *mysql.MySQLError
-		if errors.As(err, &mySQLError) {
-			if mySQLError.Number == 1062 && strings.Contains(mySQLError.Message, "users_uc_email") {
-				return ErrDuplicateEmail
-			}
+		if isDuplicate(err, emailKey) {
+			return 0, ErrDuplicateEmail
 		}
-		return err
+		return 0, err
 	}
 
-	return nil
+	return id, nil
 }
 
-func (m *UserModel) Authenticate(email, password string) (int, error) {
+func (m *UserModel) Authenticate(ctx context.Context, email, password string) (int, error) {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
 	var id int
 	var hashedPassword []byte
 
 	stmt := "SELECT id, hashed_password FROM users WHERE email = ?"
 
-	err := m.DB.QueryRow(stmt, email).Scan(&id, &hashedPassword)
+	err := m.DB.QueryRowContext(ctx, m.Dialect.rebind(stmt), email).Scan(&id, &hashedPassword)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return 0, ErrInvalidCredentials
@@ -82,22 +93,29 @@ func (m *UserModel) Authenticate(email, password string) (int, error) {
 	return id, nil
 }
 
-func (m *UserModel) Exists(id int) (bool, error) {
+func (m *UserModel) Exists(ctx context.Context, id int) (bool, error) {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
 	var exists bool
 
 	stmt := "SELECT EXISTS(SELECT true FROM users WHERE id = ?)"
-	err := m.DB.QueryRow(stmt, id).Scan(&exists)
+	err := m.DB.QueryRowContext(ctx, m.Dialect.rebind(stmt), id).Scan(&exists)
 	return exists, err
 }
 
-func (m *UserModel) Get(id int) (*User, error) {
+func (m *UserModel) Get(ctx context.Context, id int) (*User, error) {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
 	var (
-		name    string
-		email   string
-		created time.Time
+		name     string
+		email    string
+		created  time.Time
+		verified bool
 	)
-	stmt := "SELECT name, email, created FROM users WHERE id = ?"
-	err := m.DB.QueryRow(stmt, id).Scan(&name, &email, &created)
+	stmt := "SELECT name, email, created, verified FROM users WHERE id = ?"
+	err := m.DB.QueryRowContext(ctx, m.Dialect.rebind(stmt), id).Scan(&name, &email, &created, &verified)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, ErrNoRecord
@@ -106,24 +124,58 @@ func (m *UserModel) Get(id int) (*User, error) {
 	}
 
 	user := User{
-		ID:      id,
-		Name:    name,
-		Email:   email,
-		Created: created,
+		ID:       id,
+		Name:     name,
+		Email:    email,
+		Created:  created,
+		Verified: verified,
 	}
 	return &user, nil
 }
 
-func (m *UserModel) UpdatePassword(id int, password string) error {
+func (m *UserModel) UpdatePassword(ctx context.Context, id int, password string) error {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), 12)
 	if err != nil {
 		return err
 	}
 
 	stmt := "UPDATE users SET hashed_password = ? WHERE id = ?"
-	_, err = m.DB.Exec(stmt, hashedPassword, id)
+	_, err = m.DB.ExecContext(ctx, m.Dialect.rebind(stmt), string(hashedPassword), id)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// Verify marks the user's email address as verified. It returns ErrNoRecord
+// if there's no such user.
+func (m *UserModel) Verify(ctx context.Context, id int) error {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	stmt := "UPDATE users SET verified = TRUE WHERE id = ?"
+	result, err := m.DB.ExecContext(ctx, m.Dialect.rebind(stmt), id)
 	if err != nil {
 		return err
 	}
+
+	// MySQL only counts rows which actually changed, so one already verified
+	// has to be told apart from one which doesn't exist
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		exists, err := m.Exists(ctx, id)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return ErrNoRecord
+		}
+	}
 	return nil
 }
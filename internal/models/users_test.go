@@ -1,6 +1,7 @@
 package models
 
 import (
+	"context"
 	"testing"
 
 	"snippetbox.cozycole.net/internal/assert"
@@ -35,14 +36,43 @@ func TestUserModelExists(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			db := newTestDB(t)
+			db, dialect := newTestDB(t)
 
-			m := UserModel{db}
+			m := UserModel{DB: db, Dialect: dialect}
 
-			exists, err := m.Exists(tt.userID)
+			exists, err := m.Exists(context.Background(), tt.userID)
 
 			assert.Equal(t, exists, tt.want)
 			assert.NilError(t, err)
 		})
 	}
 }
+
+func TestUserModelVerify(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	ctx := context.Background()
+	db, dialect := newTestDB(t)
+	m := UserModel{DB: db, Dialect: dialect}
+
+	id, err := m.Insert(ctx, "Bob Brown", "bob@example.com", "pa$$word")
+	assert.NilError(t, err)
+
+	user, err := m.Get(ctx, id)
+	assert.NilError(t, err)
+	assert.Equal(t, user.Email, "bob@example.com")
+	assert.Equal(t, user.Verified, false)
+
+	// Verifying twice is harmless
+	for i := 0; i < 2; i++ {
+		assert.NilError(t, m.Verify(ctx, id))
+	}
+
+	user, err = m.Get(ctx, id)
+	assert.NilError(t, err)
+	assert.Equal(t, user.Verified, true)
+
+	assert.Equal(t, m.Verify(ctx, id+1), ErrNoRecord)
+}
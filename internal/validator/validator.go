@@ -64,3 +64,19 @@ func ValidEmail(email string) bool {
 	_, err := mail.ParseAddress(email)
 	return err == nil
 }
+
+// PermittedChars returns true if every character in value is one of the
+// characters in chars.
+func PermittedChars(value string, chars string) bool {
+	for _, c := range value {
+		if !strings.ContainsRune(chars, c) {
+			return false
+		}
+	}
+	return true
+}
+
+// MaxItems returns true if values holds no more than n items.
+func MaxItems[T any](values []T, n int) bool {
+	return len(values) <= n
+}